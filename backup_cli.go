@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/askarbtw/url-shortener-golang/services"
+)
+
+// runBackupCommand writes a backup archive to a file:
+//
+//	url-shortener backup FILE
+func runBackupCommand(service *services.BackupService, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: backup FILE")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	backup, err := service.CreateBackup(ctx)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	file, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	err = backup.Write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(flags.Arg(0))
+		return err
+	}
+
+	for _, entry := range backup.Manifest().Files {
+		fmt.Printf("%-18s %d records\n", entry.Name, entry.Records)
+	}
+	fmt.Printf("Backup written to %s\n", flags.Arg(0))
+	return nil
+}
+
+// runRestoreCommand verifies a backup archive and loads it into the configured storage:
+//
+//	url-shortener restore [-verify] FILE
+func runRestoreCommand(service *services.BackupService, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	verifyOnly := flags.Bool("verify", false, "check the archive without restoring it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: restore [-verify] FILE")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := service.Restore(ctx, flags.Arg(0), !*verifyOnly)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup from %s verified\n", report.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	for _, file := range report.Files {
+		if report.Applied {
+			fmt.Printf("%-18s %d records, %d restored, %d failed\n", file.Name, file.Records, file.Restored, file.Failed)
+		} else {
+			fmt.Printf("%-18s %d records\n", file.Name, file.Records)
+		}
+	}
+	for _, restoreErr := range report.Errors {
+		fmt.Printf("  %s record %d: %s\n", restoreErr.File, restoreErr.Record, restoreErr.Message)
+	}
+	for _, fingerprint := range report.MissingAPIKeys {
+		fmt.Printf("  trusted API key %s is not configured in TRUSTED_API_KEYS\n", fingerprint)
+	}
+	return nil
+}
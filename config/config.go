@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +20,84 @@ type Config struct {
 	RedisURI      string
 	RedisPassword string
 	CacheTTL      int // Time to live for cached items in seconds
+	GeoIPDBPath   string
+
+	TrustedProxies []string // Proxies, as IPs or CIDR ranges, whose X-Forwarded-For is believed
+
+	// Response for links whose activeFrom is still in the future
+	NotYetAvailableStatus  int
+	NotYetAvailableMessage string
+	NotYetAvailableURL     string
+
+	SchedulerInterval time.Duration // How often scheduled destination changes are applied
+
+	// Trash
+	TrashRetention time.Duration // How long deleted URLs can be restored
+	CodeQuarantine time.Duration // How long deleted short codes cannot be re-issued
+	PurgeInterval  time.Duration // How often the trash is purged
+
+	// Short code generation
+	ShortCodeStrategy        string // random, counter or obfuscated
+	ShortCodeAlphabet        string
+	ShortCodeLength          int // Length of random codes, minimum length of obfuscated codes
+	ShortCodeSalt            string
+	ShortCodeMaxAttempts     int
+	ShortCodeGrowthThreshold float64 // Collision rate at which random codes grow by one character
+	ShortCodeGrowthWindow    int     // Number of attempts the collision rate is measured over
+	BlockedWordsFile         string  // Word list for the short code filter; built-in list when empty
+	BotPatternsFile          string  // User-Agent fragments of bots; built-in list when empty
+
+	// ID allocation for the counter and obfuscated strategies
+	IDAllocatorBackend string // mongo or redis
+	IDBlockSize        int    // Number of IDs leased from the store at a time
+	IDLeaseFile        string // Where this instance persists its current lease
+
+	// Destination page metadata
+	MetadataWorkers      int // Number of concurrent fetches; 0 disables fetching
+	MetadataQueueSize    int
+	MetadataFetchTimeout time.Duration
+	MetadataMaxBytes     int  // How much of a page is read
+	MetadataAllowPrivate bool // Allow fetching destinations on private networks
+
+	// Interstitial previews
+	TrustedAPIKeys        []string
+	ForcePreviewUntrusted bool // Preview links not created with a trusted API key
+
+	// Link health checks
+	HealthCheckInterval     time.Duration // How often each link is rechecked
+	HealthCheckConcurrency  int           // Number of concurrent checks; 0 disables checking
+	HealthCheckBatchSize    int           // Links checked per round
+	HealthCheckTimeout      time.Duration
+	HealthCheckHostDelay    time.Duration // Minimum time between requests to the same host
+	HealthCheckAllowPrivate bool
+	HealthWebhookURL        string // Notified when a link breaks or recovers
+
+	// Outgoing webhooks
+	WebhookWorkers         int // Number of concurrent deliveries; 0 stops delivering
+	WebhookTimeout         time.Duration
+	WebhookMaxAttempts     int           // Attempts before a delivery moves to the dead letters
+	WebhookRetryBase       time.Duration // Wait before the first retry; doubled for each later one
+	WebhookClickMilestones []int         // Click counts that emit link.click_milestone
+	WebhookAllowPrivate    bool
+
+	// Live click streams
+	AdminToken    string        // Required by the all-links stream; empty disables it
+	LiveHeartbeat time.Duration // Interval between keep-alive comments
+	LiveBuffer    int           // Clicks buffered per client before dropping
+
+	// Unique visitors
+	UniqueVisitorRetention int // Days unique visitor counts are kept for
+	UniqueVisitorDays      int // Days returned in statistics unless ?days= is given
+
+	// Click events
+	ClickEventRetentionDays int // Days raw click events are kept; rollups are kept forever. 0 keeps events forever
+
+	// Imports
+	ImportDir      string // Where uploaded import files are kept until their job completes
+	ImportMaxBytes int    // Largest accepted import upload
+
+	// Backups
+	RestoreMaxBytes int // Largest accepted backup archive upload
 }
 
 // LoadConfig loads the application configuration from environment variables
@@ -44,7 +125,112 @@ func LoadConfig() *Config {
 		RedisURI:      getEnv("REDIS_URI", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		CacheTTL:      cacheTTL,
+		GeoIPDBPath:   getEnv("GEOIP_DB_PATH", ""),
+
+		TrustedProxies: getListEnv("TRUSTED_PROXIES"),
+
+		NotYetAvailableStatus:  getIntEnv("NOT_YET_AVAILABLE_STATUS", 404),
+		NotYetAvailableMessage: getEnv("NOT_YET_AVAILABLE_MESSAGE", "This link is not available yet"),
+		NotYetAvailableURL:     getEnv("NOT_YET_AVAILABLE_URL", ""),
+
+		SchedulerInterval: getDurationEnv("SCHEDULER_INTERVAL", time.Minute),
+
+		TrashRetention: getDurationEnv("TRASH_RETENTION", 30*24*time.Hour),
+		CodeQuarantine: getDurationEnv("CODE_QUARANTINE", 90*24*time.Hour),
+		PurgeInterval:  getDurationEnv("PURGE_INTERVAL", time.Hour),
+
+		ShortCodeStrategy:        getEnv("SHORTCODE_STRATEGY", "random"),
+		ShortCodeAlphabet:        getEnv("SHORTCODE_ALPHABET", "default"),
+		ShortCodeLength:          getIntEnv("SHORTCODE_LENGTH", 6),
+		ShortCodeSalt:            getEnv("SHORTCODE_SALT", ""),
+		ShortCodeMaxAttempts:     getIntEnv("SHORTCODE_MAX_ATTEMPTS", 10),
+		ShortCodeGrowthThreshold: getFloatEnv("SHORTCODE_GROWTH_THRESHOLD", 0.1),
+		ShortCodeGrowthWindow:    getIntEnv("SHORTCODE_GROWTH_WINDOW", 100),
+		BlockedWordsFile:         getEnv("BLOCKED_WORDS_FILE", ""),
+		BotPatternsFile:          getEnv("BOT_PATTERNS_FILE", ""),
+
+		IDAllocatorBackend: getEnv("ID_ALLOCATOR_BACKEND", "mongo"),
+		IDBlockSize:        getIntEnv("ID_BLOCK_SIZE", 100),
+		IDLeaseFile:        getEnv("ID_LEASE_FILE", ""),
+
+		MetadataWorkers:      getIntEnv("METADATA_WORKERS", 2),
+		MetadataQueueSize:    getIntEnv("METADATA_QUEUE_SIZE", 100),
+		MetadataFetchTimeout: getDurationEnv("METADATA_FETCH_TIMEOUT", 5*time.Second),
+		MetadataMaxBytes:     getIntEnv("METADATA_MAX_BYTES", 1<<20),
+		MetadataAllowPrivate: getBoolEnv("METADATA_ALLOW_PRIVATE", false),
+
+		TrustedAPIKeys:        getListEnv("TRUSTED_API_KEYS"),
+		ForcePreviewUntrusted: getBoolEnv("FORCE_PREVIEW_UNTRUSTED", false),
+
+		HealthCheckInterval:     getDurationEnv("HEALTH_CHECK_INTERVAL", 24*time.Hour),
+		HealthCheckConcurrency:  getIntEnv("HEALTH_CHECK_CONCURRENCY", 5),
+		HealthCheckBatchSize:    getIntEnv("HEALTH_CHECK_BATCH_SIZE", 100),
+		HealthCheckTimeout:      getDurationEnv("HEALTH_CHECK_TIMEOUT", 10*time.Second),
+		HealthCheckHostDelay:    getDurationEnv("HEALTH_CHECK_HOST_DELAY", 2*time.Second),
+		HealthCheckAllowPrivate: getBoolEnv("HEALTH_CHECK_ALLOW_PRIVATE", false),
+		HealthWebhookURL:        getEnv("HEALTH_WEBHOOK_URL", ""),
+
+		WebhookWorkers:         getIntEnv("WEBHOOK_WORKERS", 2),
+		WebhookTimeout:         getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:     getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBase:       getDurationEnv("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookClickMilestones: getIntListEnv("WEBHOOK_CLICK_MILESTONES", []int{100, 1000, 10000, 100000}),
+		WebhookAllowPrivate:    getBoolEnv("WEBHOOK_ALLOW_PRIVATE", false),
+
+		AdminToken:    getEnv("ADMIN_TOKEN", ""),
+		LiveHeartbeat: getDurationEnv("LIVE_HEARTBEAT", 15*time.Second),
+		LiveBuffer:    getIntEnv("LIVE_BUFFER", 64),
+
+		UniqueVisitorRetention: getIntEnv("UNIQUE_VISITOR_RETENTION", 90),
+		UniqueVisitorDays:      getIntEnv("UNIQUE_VISITOR_DAYS", 30),
+
+		ClickEventRetentionDays: getIntEnv("CLICK_EVENT_RETENTION_DAYS", 90),
+
+		ImportDir:      getEnv("IMPORT_DIR", "imports"),
+		ImportMaxBytes: getIntEnv("IMPORT_MAX_BYTES", 256<<20),
+
+		RestoreMaxBytes: getIntEnv("RESTORE_MAX_BYTES", 1<<30),
+	}
+}
+
+// Validate reports the first setting that cannot work, so the server refuses
+// to start rather than failing on first use
+func (c *Config) Validate() error {
+	atLeastOne := []struct {
+		name  string
+		value int
+	}{
+		{"SHORTCODE_MAX_ATTEMPTS", c.ShortCodeMaxAttempts},
+		{"ID_BLOCK_SIZE", c.IDBlockSize},
+		{"HEALTH_CHECK_BATCH_SIZE", c.HealthCheckBatchSize},
+		{"WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts},
+		{"LIVE_BUFFER", c.LiveBuffer},
 	}
+	for _, setting := range atLeastOne {
+		if setting.value < 1 {
+			return fmt.Errorf("%s must be at least 1, got %d", setting.name, setting.value)
+		}
+	}
+
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HEALTH_CHECK_INTERVAL", c.HealthCheckInterval},
+		{"WEBHOOK_RETRY_BASE", c.WebhookRetryBase},
+		{"LIVE_HEARTBEAT", c.LiveHeartbeat},
+	}
+	for _, setting := range positive {
+		if setting.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", setting.name, setting.value)
+		}
+	}
+
+	if c.IDAllocatorBackend != "mongo" && c.IDAllocatorBackend != "redis" {
+		return fmt.Errorf("ID_ALLOCATOR_BACKEND must be mongo or redis, got %q", c.IDAllocatorBackend)
+	}
+
+	return nil
 }
 
 // getEnv retrieves an environment variable or returns a default value
@@ -55,3 +241,94 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getListEnv retrieves a comma-separated environment variable as a list
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getIntListEnv retrieves a comma-separated list of integers or returns a default value
+func getIntListEnv(key string, defaultValue []int) []int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []int
+	for _, item := range getListEnv(key) {
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			log.Printf("Warning: Invalid %s value '%s', using default: %v", key, valueStr, defaultValue)
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// getIntEnv retrieves an integer environment variable or returns a default value
+func getIntEnv(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default: %d", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getFloatEnv retrieves a floating point environment variable or returns a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default: %g", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getBoolEnv retrieves a boolean environment variable or returns a default value
+func getBoolEnv(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Printf("Warning: Invalid %s value '%s', using default: %t", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getDurationEnv retrieves a duration environment variable (e.g. "30s", "24h")
+// or returns a default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(valueStr)
+	if err != nil || value <= 0 {
+		log.Printf("Warning: Invalid %s value '%s', using default: %s", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
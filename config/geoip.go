@@ -0,0 +1,66 @@
+package config
+
+import (
+	"log"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPDatabase represents an opened MaxMind country database
+type GeoIPDatabase struct {
+	Reader *maxminddb.Reader
+}
+
+// countryRecord is the subset of a GeoIP2/GeoLite2 record we read
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// OpenGeoIP opens the local GeoIP database, if one is configured
+func OpenGeoIP(config *Config) *GeoIPDatabase {
+	if config.GeoIPDBPath == "" {
+		return nil
+	}
+
+	reader, err := maxminddb.Open(config.GeoIPDBPath)
+	if err != nil {
+		log.Printf("Warning: Failed to open GeoIP database %s: %v", config.GeoIPDBPath, err)
+		return nil
+	}
+
+	log.Println("Loaded GeoIP database")
+	return &GeoIPDatabase{
+		Reader: reader,
+	}
+}
+
+// LookupCountry returns the ISO country code for an IP address, or "" if unknown
+func (g *GeoIPDatabase) LookupCountry(ip string) string {
+	if g == nil || g.Reader == nil {
+		return ""
+	}
+
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ""
+	}
+
+	var record countryRecord
+	if err := g.Reader.Lookup(parsedIP, &record); err != nil {
+		return ""
+	}
+
+	return record.Country.ISOCode
+}
+
+// Close releases the GeoIP database
+func (g *GeoIPDatabase) Close() {
+	if g.Reader != nil {
+		if err := g.Reader.Close(); err != nil {
+			log.Printf("Error closing GeoIP database: %v", err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// isAdmin reports whether the request carries the admin token, either as a
+// bearer token or, for clients such as EventSource that cannot set headers,
+// as ?token=. Nobody is an admin when no token is configured.
+func isAdmin(r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
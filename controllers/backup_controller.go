@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+)
+
+// BackupController handles HTTP requests for backups and restores
+type BackupController struct {
+	service    *services.BackupService
+	adminToken string
+}
+
+// NewBackupController creates a new instance of BackupController
+func NewBackupController(service *services.BackupService, adminToken string) *BackupController {
+	return &BackupController{
+		service:    service,
+		adminToken: adminToken,
+	}
+}
+
+// Backup downloads a backup archive of all links and analytics; admins only
+func (c *BackupController) Backup(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	backup, err := c.service.CreateBackup(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer backup.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="backup-`+backup.Manifest().CreatedAt.Format("20060102-150405")+`.tar.gz"`)
+
+	if err := backup.Write(w); err != nil {
+		// The response has started, so abort it rather than end it cleanly
+		log.Printf("Backup failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Restore verifies the backup archive in the request body and loads it; admins only
+func (c *BackupController) Restore(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	verifyOnly := false
+	if value := r.URL.Query().Get("verify"); value != "" {
+		var err error
+		verifyOnly, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid verify", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Restore backup
+	report, err := c.service.RestoreUpload(r.Context(), r.Body, !verifyOnly)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrorInvalidBackup):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, models.ErrorBackupTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+)
+
+// exportContentTypes maps export formats to their media types
+var exportContentTypes = map[string]string{
+	services.ExportCSV:     "text/csv; charset=utf-8",
+	services.ExportNDJSON:  "application/x-ndjson",
+	services.ExportParquet: "application/vnd.apache.parquet",
+}
+
+// ExportController handles HTTP requests for data exports
+type ExportController struct {
+	service    *services.ExportService
+	adminToken string
+}
+
+// NewExportController creates a new instance of ExportController
+func NewExportController(service *services.ExportService, adminToken string) *ExportController {
+	return &ExportController{
+		service:    service,
+		adminToken: adminToken,
+	}
+}
+
+// ExportLinks streams the links created in a date range; admins only
+func (c *ExportController) ExportLinks(w http.ResponseWriter, r *http.Request) {
+	c.export(w, r, "links", c.service.ExportLinks)
+}
+
+// ExportClicks streams the click events of a date range, optionally of one link; admins only
+func (c *ExportController) ExportClicks(w http.ResponseWriter, r *http.Request) {
+	c.export(w, r, "clicks", c.service.ExportClicks)
+}
+
+// export parses the format and date range of an export and streams it to the response
+func (c *ExportController) export(w http.ResponseWriter, r *http.Request, name string, run func(context.Context, io.Writer, string, models.ExportFilter) error) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = services.ExportCSV
+	}
+	if !services.ValidFormat(format) {
+		http.Error(w, models.ErrorInvalidExport.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeBound(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeBound(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to", http.StatusBadRequest)
+		return
+	}
+	filter := models.ExportFilter{
+		From:      from,
+		To:        to,
+		ShortCode: query.Get("shortCode"),
+	}
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+"-"+time.Now().UTC().Format("20060102-150405")+"."+format+`"`)
+
+	err = run(r.Context(), w, format, filter)
+	if err != nil {
+		// The response is already under way, so abort the connection to
+		// show the client the export is incomplete
+		log.Printf("Error exporting %s: %v", name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseTimeBound reads a time range bound given as RFC 3339 or YYYY-MM-DD (UTC midnight)
+func parseTimeBound(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
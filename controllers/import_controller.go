@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/gorilla/mux"
+)
+
+// importFormats maps upload media types to import formats
+var importFormats = map[string]string{
+	"text/csv":             models.ImportCSV,
+	"application/json":     models.ImportJSON,
+	"application/x-ndjson": models.ImportNDJSON,
+	"application/jsonl":    models.ImportNDJSON,
+}
+
+// ImportController handles HTTP requests for link imports
+type ImportController struct {
+	service    *services.ImportService
+	adminToken string
+}
+
+// NewImportController creates a new instance of ImportController
+func NewImportController(service *services.ImportService, adminToken string) *ImportController {
+	return &ImportController{
+		service:    service,
+		adminToken: adminToken,
+	}
+}
+
+// CreateImport queues an import of the file in the request body; admins only
+func (c *ImportController) CreateImport(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format = importFormats[mediaType]
+	}
+	conflict := query.Get("conflict")
+	if conflict == "" {
+		conflict = models.ConflictSkip
+	}
+	dryRun := false
+	if value := query.Get("dryRun"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid dryRun", http.StatusBadRequest)
+			return
+		}
+	}
+	source := query.Get("name")
+	if source == "" {
+		source = "upload"
+	}
+
+	// Create import job
+	job, err := c.service.CreateJob(r.Body, source, format, conflict, dryRun)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetImports lists the latest import jobs; admins only
+func (c *ImportController) GetImports(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	jobs, err := c.service.GetJobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []models.ImportJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetImport retrieves an import job with its progress, errors and renames; admins only
+func (c *ImportController) GetImport(w http.ResponseWriter, r *http.Request) {
+	c.handleJob(w, r, c.service.GetJob)
+}
+
+// CancelImport stops an import job; admins only
+func (c *ImportController) CancelImport(w http.ResponseWriter, r *http.Request) {
+	c.handleJob(w, r, c.service.CancelJob)
+}
+
+// ResumeImport continues a stopped import job after its last processed record; admins only
+func (c *ImportController) ResumeImport(w http.ResponseWriter, r *http.Request) {
+	c.handleJob(w, r, c.service.ResumeJob)
+}
+
+// handleJob applies an action to the import job in the path and returns the job
+func (c *ImportController) handleJob(w http.ResponseWriter, r *http.Request, action func(string) (models.ImportJob, error)) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	job, err := action(id)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// writeImportError maps import errors to HTTP responses
+func writeImportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrorInvalidImport):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrorImportNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrorImportTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, models.ErrorImportState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
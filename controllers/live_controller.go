@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/gorilla/mux"
+)
+
+// liveWriteTimeout bounds each write so a stalled client cannot hold its stream open
+const liveWriteTimeout = 10 * time.Second
+
+// LiveController streams clicks to clients as Server-Sent Events
+type LiveController struct {
+	urls       *services.URLService
+	clicks     *services.ClickService
+	adminToken string
+	heartbeat  time.Duration
+}
+
+// NewLiveController creates a new instance of LiveController
+func NewLiveController(urls *services.URLService, clicks *services.ClickService, adminToken string, heartbeat time.Duration) *LiveController {
+	return &LiveController{
+		urls:       urls,
+		clicks:     clicks,
+		adminToken: adminToken,
+		heartbeat:  heartbeat,
+	}
+}
+
+// StreamLink streams the clicks of one short link
+func (c *LiveController) StreamLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	// Check the URL exists
+	_, err := c.urls.GetURL(shortCode)
+	if err != nil {
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	}
+
+	includeBots, ok := parseTraffic(r)
+	if !ok {
+		http.Error(w, "Invalid traffic", http.StatusBadRequest)
+		return
+	}
+
+	c.stream(w, r, shortCode, includeBots)
+}
+
+// StreamAll streams the clicks of every short link; admins only
+func (c *LiveController) StreamAll(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r, c.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	includeBots, ok := parseTraffic(r)
+	if !ok {
+		http.Error(w, "Invalid traffic", http.StatusBadRequest)
+		return
+	}
+
+	c.stream(w, r, "", includeBots)
+}
+
+// stream writes clicks until the client disconnects or the server shuts down
+func (c *LiveController) stream(w http.ResponseWriter, r *http.Request, shortCode string, includeBots bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	controller := http.NewResponseController(w)
+
+	subscription := c.clicks.Subscribe(shortCode, includeBots)
+	defer c.clicks.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	// write sends one chunk, giving up on clients that stop reading
+	write := func(chunk string) bool {
+		controller.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if _, err := fmt.Fprint(w, chunk); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !write(": connected\n\n") {
+		return
+	}
+
+	heartbeat := time.NewTicker(c.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+
+			// Tell the client about clicks it missed while falling behind
+			if dropped := subscription.Dropped(); dropped > 0 {
+				if !write(fmt.Sprintf("event: dropped\ndata: {\"count\":%d}\n\n", dropped)) {
+					return
+				}
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if !write("event: click\ndata: " + string(data) + "\n\n") {
+				return
+			}
+		}
+	}
+}
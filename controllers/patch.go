@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// decodeURLPatch parses a JSON Merge Patch (RFC 7396) document for a URL
+func decodeURLPatch(body io.Reader) (models.URLPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil {
+		return models.URLPatch{}, fmt.Errorf("patch must be a JSON object")
+	}
+
+	var patch models.URLPatch
+	for name, raw := range fields {
+		switch name {
+		case "url":
+			if isNull(raw) {
+				return models.URLPatch{}, fmt.Errorf("url cannot be removed")
+			}
+			value, err := decodeString(name, raw)
+			if err != nil {
+				return models.URLPatch{}, err
+			}
+			patch.URL = value
+		case "title", "description", "notes":
+			value := new(string)
+			if !isNull(raw) {
+				var err error
+				if value, err = decodeString(name, raw); err != nil {
+					return models.URLPatch{}, err
+				}
+			}
+			switch name {
+			case "title":
+				patch.Title = value
+			case "description":
+				patch.Description = value
+			case "notes":
+				patch.Notes = value
+			}
+		case "metadata":
+			if isNull(raw) {
+				patch.ClearMetadata = true
+				continue
+			}
+			var metadata map[string]*string
+			if err := json.Unmarshal(raw, &metadata); err != nil {
+				return models.URLPatch{}, fmt.Errorf("metadata must be an object of strings")
+			}
+			patch.Metadata = metadata
+		case "tags":
+			tags := []string{}
+			if !isNull(raw) {
+				if err := json.Unmarshal(raw, &tags); err != nil {
+					return models.URLPatch{}, fmt.Errorf("tags must be an array of strings")
+				}
+			}
+			patch.Tags = &tags
+		case "folder":
+			value := new(string)
+			if !isNull(raw) {
+				var err error
+				if value, err = decodeString(name, raw); err != nil {
+					return models.URLPatch{}, err
+				}
+			}
+			patch.Folder = value
+		default:
+			return models.URLPatch{}, fmt.Errorf("field %q cannot be patched", name)
+		}
+	}
+
+	return patch, nil
+}
+
+// decodeString decodes a JSON string field
+func decodeString(name string, raw json.RawMessage) (*string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("%s must be a string", name)
+	}
+	return &value, nil
+}
+
+// isNull reports whether a raw JSON value is null
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// socialPreviewTemplate is served to link-unfurling crawlers instead of a redirect
+var socialPreviewTemplate = template.Must(template.New("social-preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Preview.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.ShortURL}}">
+{{- with .Preview.Title}}
+<meta property="og:title" content="{{.}}">
+<meta name="twitter:title" content="{{.}}">
+{{- end}}
+{{- with .Preview.Description}}
+<meta name="description" content="{{.}}">
+<meta property="og:description" content="{{.}}">
+<meta name="twitter:description" content="{{.}}">
+{{- end}}
+{{- if .Preview.Image}}
+<meta property="og:image" content="{{.Preview.Image}}">
+<meta name="twitter:image" content="{{.Preview.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta http-equiv="refresh" content="0; url={{.TargetURL}}">
+</head>
+<body>
+<a href="{{.TargetURL}}">{{.TargetURL}}</a>
+</body>
+</html>
+`))
+
+// interstitialTemplate shows visitors where a link goes before they follow it
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<meta name="referrer" content="no-referrer">
+<title>Link preview</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.destination { word-break: break-all; padding: .75rem; background: #f4f4f4; border-radius: 4px; }
+.host { font-weight: bold; }
+.meta { color: #666; font-size: .9rem; }
+.continue { margin-top: 1.5rem; padding: .6rem 1.2rem; background: #2563eb; color: #fff; border: none; border-radius: 4px; font: inherit; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>This link goes to</h1>
+<p class="host">{{.Host}}</p>
+{{- with .Title}}
+<p>{{.}}</p>
+{{- end}}
+<p class="destination">{{.TargetURL}}</p>
+<p class="meta">Short link created {{.CreatedAt.Format "January 2, 2006"}}</p>
+<form method="post" action="{{.ContinueURL}}">
+<button class="continue" type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// shortLink returns the public short link of a URL
+func (c *URLController) shortLink(shortCode string) string {
+	return strings.TrimSuffix(c.baseURL, "/") + "/r/" + shortCode
+}
+
+// renderSocialPreview writes the preview page for a link-unfurling crawler
+func renderSocialPreview(w http.ResponseWriter, preview models.SocialPreview, shortURL, targetURL string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	err := socialPreviewTemplate.Execute(w, struct {
+		Preview   models.SocialPreview
+		ShortURL  string
+		TargetURL string
+	}{preview, shortURL, targetURL})
+	if err != nil {
+		log.Printf("Error rendering social preview: %v", err)
+	}
+}
+
+// renderInterstitial writes the preview page shown before following a link.
+// The fetched title is only shown while it belongs to the current destination.
+func renderInterstitial(w http.ResponseWriter, url models.URL, targetURL string, continueURL string) {
+	var title string
+	if url.Page != nil && url.Page.FetchedURL == url.OriginalURL {
+		title = url.Page.Title
+	}
+
+	var host string
+	if parsed, err := neturl.Parse(targetURL); err == nil {
+		host = parsed.Hostname()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := interstitialTemplate.Execute(w, struct {
+		Host        string
+		Title       string
+		TargetURL   string
+		ContinueURL string // Posting here counts the click and redirects
+		CreatedAt   time.Time
+	}{host, title, targetURL, continueURL, url.CreatedAt})
+	if err != nil {
+		log.Printf("Error rendering interstitial: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/gorilla/mux"
+)
+
+// TagController handles HTTP requests for tag and folder operations
+type TagController struct {
+	service *services.URLService
+}
+
+// NewTagController creates a new instance of TagController
+func NewTagController(service *services.URLService) *TagController {
+	return &TagController{
+		service: service,
+	}
+}
+
+// AddTags adds tags to a URL
+func (c *TagController) AddTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.TagsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Add tags
+	url, err := c.service.AddTags(shortCode, req.Tags)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(url))
+	json.NewEncoder(w).Encode(response)
+}
+
+// RemoveTag removes a tag from a URL
+func (c *TagController) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+	tag := vars["tag"]
+
+	// Remove tag
+	url, err := c.service.RemoveTag(shortCode, tag)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(url))
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTags lists every tag with its link count and click total
+func (c *TagController) GetTags(w http.ResponseWriter, r *http.Request) {
+	stats, err := c.service.GetTagStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// RenameTag renames a tag on every URL
+func (c *TagController) RenameTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+
+	var req models.RenameRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Rename tag
+	updated, err := c.service.RenameTag(tag, req.Name)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidTag) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdatedCount(w, updated)
+}
+
+// DeleteTag removes a tag from every URL
+func (c *TagController) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+
+	// Delete tag
+	updated, err := c.service.DeleteTag(tag)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidTag) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdatedCount(w, updated)
+}
+
+// GetFolders lists every folder with its link count and click total
+func (c *TagController) GetFolders(w http.ResponseWriter, r *http.Request) {
+	stats, err := c.service.GetFolderStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// RenameFolder moves every URL in a folder to another folder
+func (c *TagController) RenameFolder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	folder := vars["folder"]
+
+	var req models.RenameRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Rename folder
+	updated, err := c.service.RenameFolder(folder, req.Name)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidFolder) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdatedCount(w, updated)
+}
+
+// DeleteFolder takes every URL out of a folder; the URLs themselves are kept
+func (c *TagController) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	folder := vars["folder"]
+
+	// Delete folder
+	updated, err := c.service.DeleteFolder(folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdatedCount(w, updated)
+}
+
+// writeUpdatedCount responds with the number of URLs a bulk operation changed
+func writeUpdatedCount(w http.ResponseWriter, updated int) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"updated": updated})
+}
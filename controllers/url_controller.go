@@ -2,27 +2,165 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/askarbtw/url-shortener-golang/models"
 	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/askarbtw/url-shortener-golang/utils"
 	"github.com/gorilla/mux"
 )
 
 // URLController handles HTTP requests for URL operations
 type URLController struct {
-	service *services.URLService
-	baseURL string
+	service   *services.URLService
+	redirects *services.RedirectService
+	clicks    *services.ClickService
+	baseURL   string
+	proxies   *utils.TrustedProxies
+
+	uniqueVisitorDays int // Days of unique visitors in statistics by default
 }
 
 // NewURLController creates a new instance of URLController
-func NewURLController(service *services.URLService, baseURL string) *URLController {
+func NewURLController(service *services.URLService, redirects *services.RedirectService, clicks *services.ClickService, baseURL string, proxies *utils.TrustedProxies, uniqueVisitorDays int) *URLController {
 	return &URLController{
-		service: service,
-		baseURL: baseURL,
+		service:   service,
+		redirects: redirects,
+		clicks:    clicks,
+		baseURL:   baseURL,
+		proxies:   proxies,
+
+		uniqueVisitorDays: uniqueVisitorDays,
+	}
+}
+
+// newURLResponse builds the API representation of a URL
+func newURLResponse(url models.URL) models.URLResponse {
+	return models.URLResponse{
+		ID:               url.ID,
+		URL:              url.OriginalURL,
+		ShortCode:        url.ShortCode,
+		Title:            url.Title,
+		Description:      url.Description,
+		Notes:            url.Notes,
+		Metadata:         url.Metadata,
+		Tags:             url.Tags,
+		Folder:           url.Folder,
+		Page:             url.Page,
+		Health:           url.Health,
+		Version:          url.Version,
+		Rules:            url.Rules,
+		Variants:         url.Variants,
+		ActiveFrom:       url.ActiveFrom,
+		ScheduledChanges: url.ScheduledChanges,
+		RedirectOptions:  url.RedirectOptions,
+		SocialPreview:    url.SocialPreview,
+		CreatedAt:        url.CreatedAt,
+		UpdatedAt:        url.UpdatedAt,
+		DeletedAt:        url.DeletedAt,
+	}
+}
+
+// newURLStatsResponse builds the API representation of a URL's statistics
+func newURLStatsResponse(url models.URL, allTraffic bool) models.URLStatsResponse {
+	accessCount := url.AccessCount
+	if allTraffic {
+		accessCount += url.BotCount
+	}
+
+	return models.URLStatsResponse{
+		ID:          url.ID,
+		URL:         url.OriginalURL,
+		ShortCode:   url.ShortCode,
+		Title:       url.Title,
+		Description: url.Description,
+		Notes:       url.Notes,
+		Metadata:    url.Metadata,
+		Tags:        url.Tags,
+		Folder:      url.Folder,
+		Page:        url.Page,
+		Health:      url.Health,
+		CreatedAt:   url.CreatedAt,
+		UpdatedAt:   url.UpdatedAt,
+		AccessCount: accessCount,
+		BotCount:    url.BotCount,
+		Variants:    url.Variants,
+	}
+}
+
+// etag returns the entity tag of a URL's current version
+func etag(url models.URL) string {
+	return `"` + strconv.Itoa(url.Version) + `"`
+}
+
+// parseIfMatch reads the If-Match header. It returns a nil version when the
+// header is absent or "*", and false when it names no usable version; weak
+// tags never match because If-Match requires strong comparison.
+func parseIfMatch(r *http.Request) (*int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
 	}
+
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return nil, false
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil {
+		return nil, false
+	}
+	return &version, true
+}
+
+// queryInt parses an integer query parameter, using fallback when it is absent
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseTraffic reads ?traffic=, which is "human" (the default) or "all" to
+// include bots; it returns false for any other value
+func parseTraffic(r *http.Request) (bool, bool) {
+	switch r.URL.Query().Get("traffic") {
+	case "", "human":
+		return false, true
+	case "all":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// maxActorNoteLength bounds the X-Actor note kept with a revision, in characters
+const maxActorNoteLength = 100
+
+// actorFromRequest identifies who is making a change by the fingerprint of
+// its API key. The X-Actor header is kept alongside as an unverified note.
+func actorFromRequest(r *http.Request) models.Actor {
+	actor := models.Actor{Name: utils.HashAPIKey(r.Header.Get("X-API-Key"))}
+	if actor.Name == "" {
+		actor.Name = "anonymous"
+	}
+
+	note := []rune(strings.TrimSpace(r.Header.Get("X-Actor")))
+	if len(note) > maxActorNoteLength {
+		note = note[:maxActorNoteLength]
+	}
+	actor.Note = string(note)
+	return actor
+}
+
+// variantCookieName returns the cookie remembering a visitor's variant for a link
+func variantCookieName(shortCode string) string {
+	return "variant_" + shortCode
 }
 
 // CreateURL handles the creation of a new short URL
@@ -35,20 +173,18 @@ func (c *URLController) CreateURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create URL
-	url, err := c.service.CreateURL(req.URL)
+	url, err := c.service.CreateURL(req, actorFromRequest(r), r.Header.Get("X-API-Key"))
 	if err != nil {
+		if errors.Is(err, models.ErrorShortCodeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Create response
-	response := models.URLResponse{
-		ID:        url.ID,
-		URL:       url.OriginalURL,
-		ShortCode: url.ShortCode,
-		CreatedAt: url.CreatedAt,
-		UpdatedAt: url.UpdatedAt,
-	}
+	response := newURLResponse(url)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -68,15 +204,10 @@ func (c *URLController) GetURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create response
-	response := models.URLResponse{
-		ID:        url.ID,
-		URL:       url.OriginalURL,
-		ShortCode: url.ShortCode,
-		CreatedAt: url.CreatedAt,
-		UpdatedAt: url.UpdatedAt,
-	}
+	response := newURLResponse(url)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(url))
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -84,6 +215,13 @@ func (c *URLController) GetURL(w http.ResponseWriter, r *http.Request) {
 func (c *URLController) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
+	extraPath := vars["path"]
+
+	// A trailing "+" asks for the preview page instead of the redirect, and
+	// the preview page's Continue button posts back to follow the link
+	previewRequested := strings.HasSuffix(shortCode, services.PreviewShortCodeSuffix)
+	continued := r.Method == http.MethodPost
+	shortCode = strings.TrimSuffix(shortCode, services.PreviewShortCodeSuffix)
 
 	// Get URL
 	url, err := c.service.GetURL(shortCode)
@@ -92,21 +230,80 @@ func (c *URLController) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Increment access count
-	go c.service.IncrementAccessCount(shortCode)
+	// Extra path segments are only accepted by links that forward them
+	if extraPath != "" && (url.RedirectOptions == nil || !url.RedirectOptions.ForwardPath) {
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	}
 
-	// Prepare the target URL
-	targetURL := url.OriginalURL
+	// Hold back links that are not active yet
+	if unavailable, ok := c.redirects.NotYetAvailable(url, time.Now()); ok {
+		if unavailable.RedirectURL != "" {
+			http.Redirect(w, r, unavailable.RedirectURL, http.StatusTemporaryRedirect)
+			return
+		}
+		http.Error(w, unavailable.Message, unavailable.StatusCode)
+		return
+	}
+
+	// Pick the destination for this visitor
+	visitor := models.Visitor{
+		IP:             c.proxies.ClientIP(r),
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Time:           time.Now(),
+		Bot:            c.clicks.IsBot(r),
+	}
+	if cookie, err := r.Cookie(variantCookieName(shortCode)); err == nil {
+		visitor.AssignedVariant = cookie.Value
+	}
+	targetURL, variant := c.redirects.BuildTarget(url, visitor, extraPath, r.URL.Query())
 
 	// Make sure we have a protocol prefix
 	if !strings.HasPrefix(targetURL, "http://") && !strings.HasPrefix(targetURL, "https://") {
 		targetURL = "https://" + targetURL
 	}
 
+	// Link-unfurling crawlers get the link's own preview instead of a redirect
+	if utils.IsLinkPreviewCrawler(visitor.UserAgent) {
+		if preview, ok := c.redirects.SocialPreview(url); ok {
+			visitor.Bot = true
+			go c.clicks.Record(shortCode, "", visitor, r.Referer())
+			renderSocialPreview(w, preview, c.shortLink(shortCode), targetURL)
+			return
+		}
+	}
+
+	// Remember the variant so the visitor keeps seeing it
+	if variant != "" && variant != visitor.AssignedVariant {
+		http.SetCookie(w, &http.Cookie{
+			Name:     variantCookieName(shortCode),
+			Value:    variant,
+			Path:     "/r/" + shortCode,
+			MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	// Show where the link goes before following it; the click is only
+	// recorded once the visitor continues
+	if !continued && c.redirects.RequiresPreview(url, previewRequested) {
+		renderInterstitial(w, url, targetURL, r.URL.RequestURI())
+		return
+	}
+
+	// Record the click
+	go c.clicks.Record(shortCode, variant, visitor, r.Referer())
+
 	log.Printf("Redirecting %s to %s", shortCode, targetURL)
 
 	// Redirect to the original URL
-	http.Redirect(w, r, targetURL, http.StatusTemporaryRedirect)
+	status := http.StatusTemporaryRedirect
+	if continued {
+		status = http.StatusSeeOther
+	}
+	http.Redirect(w, r, targetURL, status)
 }
 
 // UpdateURL updates an existing URL
@@ -114,6 +311,12 @@ func (c *URLController) UpdateURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
+	expectedVersion, ok := parseIfMatch(r)
+	if !ok {
+		http.Error(w, models.ErrorVersionMismatch.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
 	var req models.UpdateURLRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -122,21 +325,212 @@ func (c *URLController) UpdateURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update URL
-	url, err := c.service.UpdateURL(shortCode, req.URL)
+	url, err := c.service.UpdateURL(shortCode, req.URL, actorFromRequest(r), expectedVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrorVersionMismatch):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, models.ErrorURLNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(url))
+	json.NewEncoder(w).Encode(response)
+}
+
+// PatchURL applies a JSON Merge Patch to a URL
+func (c *URLController) PatchURL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	expectedVersion, ok := parseIfMatch(r)
+	if !ok {
+		http.Error(w, models.ErrorVersionMismatch.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
+	patch, err := decodeURLPatch(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Patch URL
+	url, err := c.service.PatchURL(shortCode, patch, actorFromRequest(r), expectedVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrorVersionMismatch):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, models.ErrorURLNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
 	// Create response
-	response := models.URLResponse{
-		ID:        url.ID,
-		URL:       url.OriginalURL,
-		ShortCode: url.ShortCode,
-		CreatedAt: url.CreatedAt,
-		UpdatedAt: url.UpdatedAt,
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(url))
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateRules replaces the targeting rules of a URL
+func (c *URLController) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.UpdateRulesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
+	// Update rules
+	url, err := c.service.UpdateRules(shortCode, req.Rules)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateVariants replaces the A/B variants of a URL
+func (c *URLController) UpdateVariants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.UpdateVariantsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Update variants
+	url, err := c.service.UpdateVariants(shortCode, req.Variants)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateSchedule replaces the activation time and scheduled changes of a URL
+func (c *URLController) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.UpdateScheduleRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Update schedule
+	url, err := c.service.UpdateSchedule(shortCode, req.ActiveFrom, req.ScheduledChanges)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateRedirectOptions replaces the redirect options of a URL
+func (c *URLController) UpdateRedirectOptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.RedirectOptions
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Update redirect options
+	url, err := c.service.UpdateRedirectOptions(shortCode, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateSocialPreview replaces the social preview of a URL
+func (c *URLController) UpdateSocialPreview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	var req models.SocialPreview
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Update social preview
+	url, err := c.service.UpdateSocialPreview(shortCode, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -146,9 +540,19 @@ func (c *URLController) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
+	expectedVersion, ok := parseIfMatch(r)
+	if !ok {
+		http.Error(w, models.ErrorVersionMismatch.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
 	// Delete URL
-	err := c.service.DeleteURL(shortCode)
+	err := c.service.DeleteURL(shortCode, expectedVersion)
 	if err != nil {
+		if errors.Is(err, models.ErrorVersionMismatch) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
@@ -156,6 +560,142 @@ func (c *URLController) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RestoreURL moves a URL out of the trash
+func (c *URLController) RestoreURL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	// Restore URL
+	url, err := c.service.RestoreURL(shortCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrorURLNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, models.ErrorShortCodeExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTrash lists the URLs in the trash
+func (c *URLController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	// Get deleted URLs
+	urls, err := c.service.GetDeletedURLs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Create response
+	response := []models.URLResponse{}
+	for _, url := range urls {
+		response = append(response, newURLResponse(url))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SearchURLs searches URLs by destination, short code, title, notes and tags
+func (c *URLController) SearchURLs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, err := queryInt(query.Get("page"), 1)
+	if err != nil {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), services.DefaultSearchLimit)
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	// Search URLs
+	urls, total, err := c.service.SearchURLs(query.Get("q"), page, limit)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Create response
+	response := models.SearchResponse{
+		Query:   query.Get("q"),
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+		Results: []models.URLStatsResponse{},
+	}
+	for _, url := range urls {
+		response.Results = append(response.Results, newURLStatsResponse(url, false))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetHistory lists the destination revisions of a URL
+func (c *URLController) GetHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	// Get revisions
+	revisions, err := c.service.GetHistory(shortCode)
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// Rollback restores the destination a URL had at a given revision
+func (c *URLController) Rollback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	// Roll back URL
+	url, err := c.service.Rollback(shortCode, number, actorFromRequest(r))
+	if err != nil {
+		if errors.Is(err, models.ErrorURLNotFound) || errors.Is(err, models.ErrorRevisionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Create response
+	response := newURLResponse(url)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetURLStats retrieves statistics for a URL
 func (c *URLController) GetURLStats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -168,25 +708,106 @@ func (c *URLController) GetURLStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	days, err := queryInt(r.URL.Query().Get("days"), c.uniqueVisitorDays)
+	if err != nil || days < 1 {
+		http.Error(w, "Invalid days", http.StatusBadRequest)
+		return
+	}
+	allTraffic, ok := parseTraffic(r)
+	if !ok {
+		http.Error(w, "Invalid traffic", http.StatusBadRequest)
+		return
+	}
+
 	// Create response
-	response := models.URLStatsResponse{
-		ID:          url.ID,
-		URL:         url.OriginalURL,
-		ShortCode:   url.ShortCode,
-		CreatedAt:   url.CreatedAt,
-		UpdatedAt:   url.UpdatedAt,
-		AccessCount: url.AccessCount,
+	response := newURLStatsResponse(url, allTraffic)
+	uniques, err := c.clicks.UniqueVisitors(shortCode, days)
+	if err != nil {
+		log.Printf("Error counting unique visitors: %v", err)
+	} else {
+		response.UniqueVisitors = &uniques
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTimeSeries retrieves the clicks of a URL per hour or day
+func (c *URLController) GetTimeSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := vars["shortCode"]
+
+	// Check the URL exists
+	_, err := c.service.GetURL(shortCode)
+	if err != nil {
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = models.GranularityDay
+	}
+
+	// Default to the last 48 hours or 30 days
+	to, err := parseTimeBound(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to", http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from, err := parseTimeBound(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from", http.StatusBadRequest)
+		return
+	}
+	if from.IsZero() {
+		if granularity == models.GranularityHour {
+			from = to.Add(-48 * time.Hour)
+		} else {
+			from = to.AddDate(0, 0, -30)
+		}
+	}
+
+	// Create response
+	response, err := c.clicks.TimeSeries(shortCode, granularity, from, to)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidTimeSeries) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetAllURLStats retrieves statistics for all URLs
+// GetAllURLStats retrieves statistics for all URLs, optionally filtered by tag, folder or health
 func (c *URLController) GetAllURLStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.URLFilter{
+		Tag:    query.Get("tag"),
+		Folder: query.Get("folder"),
+		Broken: query.Get("broken") == "true",
+	}
+	allTraffic, ok := parseTraffic(r)
+	if !ok {
+		http.Error(w, "Invalid traffic", http.StatusBadRequest)
+		return
+	}
+
 	// Get all URLs with stats
-	urls, err := c.service.GetAllURLsWithStats()
+	urls, err := c.service.GetAllURLsWithStats(filter)
 	if err != nil {
+		if errors.Is(err, models.ErrorInvalidTag) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -194,14 +815,7 @@ func (c *URLController) GetAllURLStats(w http.ResponseWriter, r *http.Request) {
 	// Create response
 	var response []models.URLStatsResponse
 	for _, url := range urls {
-		response = append(response, models.URLStatsResponse{
-			ID:          url.ID,
-			URL:         url.OriginalURL,
-			ShortCode:   url.ShortCode,
-			CreatedAt:   url.CreatedAt,
-			UpdatedAt:   url.UpdatedAt,
-			AccessCount: url.AccessCount,
-		})
+		response = append(response, newURLStatsResponse(url, allTraffic))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/gorilla/mux"
+)
+
+// WebhookController handles HTTP requests for webhook subscriptions and deliveries
+type WebhookController struct {
+	service *services.WebhookService
+}
+
+// NewWebhookController creates a new instance of WebhookController
+func NewWebhookController(service *services.WebhookService) *WebhookController {
+	return &WebhookController{
+		service: service,
+	}
+}
+
+// CreateSubscription creates a webhook subscription
+func (c *WebhookController) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req models.WebhookSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Create subscription
+	subscription, err := c.service.CreateSubscription(req)
+	if err != nil {
+		if errors.Is(err, models.ErrorInvalidWebhook) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// GetSubscriptions lists the webhook subscriptions
+func (c *WebhookController) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := c.service.GetSubscriptions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if subscriptions == nil {
+		subscriptions = []models.WebhookSubscription{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// GetSubscription retrieves a webhook subscription
+func (c *WebhookController) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	subscription, err := c.service.GetSubscription(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// UpdateSubscription replaces a webhook subscription
+func (c *WebhookController) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req models.WebhookSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Update subscription
+	subscription, err := c.service.UpdateSubscription(id, req)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// DeleteSubscription deletes a webhook subscription
+func (c *WebhookController) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := c.service.DeleteSubscription(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries lists the recent deliveries of a webhook subscription
+func (c *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	deliveries, err := c.service.GetDeliveries(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	writeDeliveries(w, deliveries)
+}
+
+// GetDeadLetters lists the deliveries that were given up on
+func (c *WebhookController) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := c.service.GetDeadLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeDeliveries(w, deliveries)
+}
+
+// RetryDelivery queues a dead delivery again
+func (c *WebhookController) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	delivery, err := c.service.RetryDelivery(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// writeDeliveries responds with a list of deliveries
+func writeDeliveries(w http.ResponseWriter, deliveries []models.WebhookDelivery) {
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// writeWebhookError maps webhook errors to HTTP statuses
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrorWebhookNotFound), errors.Is(err, models.ErrorDeliveryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrorInvalidWebhook):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/services"
+)
+
+// runImportCommand imports a local file from the command line:
+//
+//	url-shortener import [-format csv|json|ndjson] [-conflict skip|overwrite|rename] [-dry-run] FILE
+//	url-shortener import -resume JOB_ID
+//
+// The job is recorded like an uploaded import, so its results can also be
+// read from the API. Interrupting the command with Ctrl-C saves its progress.
+func runImportCommand(service *services.ImportService, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "file format: csv, json or ndjson (default from the file extension)")
+	conflict := flags.String("conflict", models.ConflictSkip, "when a short code is taken: skip, overwrite or rename")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without changing anything")
+	resume := flags.String("resume", "", "continue an interrupted import job")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id := *resume
+	if id == "" {
+		if flags.NArg() != 1 {
+			return errors.New("usage: import [-format csv|json|ndjson] [-conflict skip|overwrite|rename] [-dry-run] FILE, or import -resume JOB_ID")
+		}
+
+		job, err := service.CreateLocalJob(flags.Arg(0), *format, *conflict, *dryRun)
+		if err != nil {
+			return err
+		}
+		id = job.ID.Hex()
+		fmt.Printf("Import %s started\n", id)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	job, err := service.RunJob(ctx, id, func(job models.ImportJob) {
+		fmt.Printf("\r%d/%d records (%.1f%%)", job.Processed, job.Total, job.Progress)
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+
+	if job.DryRun {
+		fmt.Println("Dry run: nothing was changed")
+	}
+	fmt.Printf("Created %d, overwritten %d, renamed %d, skipped %d, failed %d\n", job.Created, job.Overwritten, job.Renamed, job.Skipped, job.Failed)
+	for _, rename := range job.Renames {
+		fmt.Printf("  renamed %s to %s\n", rename.From, rename.To)
+	}
+	for _, importErr := range job.Errors {
+		fmt.Printf("  record %d: %s\n", importErr.Record, importErr.Message)
+	}
+
+	switch job.Status {
+	case models.ImportCompleted:
+		return nil
+	case models.ImportInterrupted:
+		fmt.Printf("Interrupted; continue with: import -resume %s\n", id)
+		return nil
+	case models.ImportFailed:
+		return fmt.Errorf("import failed: %s", job.Error)
+	default:
+		return fmt.Errorf("import %s", job.Status)
+	}
+}
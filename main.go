@@ -12,8 +12,10 @@ import (
 
 	"github.com/askarbtw/url-shortener-golang/config"
 	"github.com/askarbtw/url-shortener-golang/controllers"
+	"github.com/askarbtw/url-shortener-golang/models"
 	"github.com/askarbtw/url-shortener-golang/repositories"
 	"github.com/askarbtw/url-shortener-golang/services"
+	"github.com/askarbtw/url-shortener-golang/utils"
 	"github.com/gorilla/mux"
 )
 
@@ -22,8 +24,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, X-API-Key, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -39,6 +42,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 func main() {
 	// Load configuration
 	conf := config.LoadConfig()
+	if err := conf.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Connect to database
 	db := config.ConnectDB(conf)
@@ -52,17 +58,134 @@ func main() {
 		log.Println("Warning: Redis cache not available. Running without cache.")
 	}
 
-	// Create repository
+	// Open GeoIP database (if configured)
+	geoIP := config.OpenGeoIP(conf)
+	if geoIP != nil {
+		defer geoIP.Close()
+	}
+
+	// Create repositories
 	urlRepository := repositories.NewURLRepository(db)
+	counterRepository := repositories.NewCounterRepository(db)
+	revisionRepository := repositories.NewRevisionRepository(db)
+	webhookRepository := repositories.NewWebhookRepository(db)
+	clickRepository := repositories.NewClickRepository(db)
+	rollupRepository := repositories.NewRollupRepository(db)
+	importRepository := repositories.NewImportRepository(db)
 
 	// Create cache service
 	cacheService := services.NewCacheService(redisCache, conf.CacheTTL)
 
+	// Create ID allocator, leasing blocks of IDs from the configured store
+	var sequenceStore services.SequenceStore = counterRepository
+	if conf.IDAllocatorBackend == "redis" {
+		if redisCache == nil {
+			log.Fatal("ID_ALLOCATOR_BACKEND is redis but Redis is not available")
+		}
+		sequenceStore = repositories.NewRedisCounterRepository(redisCache)
+	}
+	idAllocator := services.NewIDAllocator(sequenceStore, services.ShortCodeSequence, uint64(conf.IDBlockSize), conf.IDLeaseFile)
+
+	// Create short code generator
+	generator, err := services.NewShortCodeGenerator(conf, idAllocator)
+	if err != nil {
+		log.Fatalf("Invalid short code configuration: %v", err)
+	}
+
+	// Load blocked word filter for short codes
+	codeFilter, err := utils.LoadCodeFilter(conf.BlockedWordsFile)
+	if err != nil {
+		log.Fatalf("Failed to load blocked words: %v", err)
+	}
+
+	// Create webhook service
+	webhookService := services.NewWebhookService(webhookRepository, conf)
+
+	// Create page metadata service (if enabled)
+	var metadataService *services.MetadataService
+	if conf.MetadataWorkers > 0 {
+		fetcher := services.NewPageFetcher(conf.MetadataFetchTimeout, int64(conf.MetadataMaxBytes), conf.MetadataAllowPrivate)
+		metadataService = services.NewMetadataService(urlRepository, cacheService, fetcher, webhookService, conf.MetadataWorkers, conf.MetadataQueueSize)
+	}
+
 	// Create service
-	urlService := services.NewURLService(urlRepository, cacheService)
+	urlService := services.NewURLService(urlRepository, revisionRepository, cacheService, generator, codeFilter, metadataService, webhookService, conf.ShortCodeMaxAttempts)
+
+	// Create redirect service
+	redirectService := services.NewRedirectService(geoIP, models.UnavailableResponse{
+		StatusCode:  conf.NotYetAvailableStatus,
+		Message:     conf.NotYetAvailableMessage,
+		RedirectURL: conf.NotYetAvailableURL,
+	}, conf.TrustedAPIKeys, conf.ForcePreviewUntrusted)
+
+	// Load the proxies trusted to report client addresses
+	proxies, err := utils.ParseTrustedProxies(conf.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Create click service
+	clickBroker := services.NewClickBroker(redisCache, conf.LiveBuffer)
+	uniqueVisitors := services.NewUniqueVisitorCounter(redisCache, conf.UniqueVisitorRetention)
+	botFilter, err := utils.LoadBotFilter(conf.BotPatternsFile)
+	if err != nil {
+		log.Fatalf("Failed to load bot patterns: %v", err)
+	}
+	clickService := services.NewClickService(urlService, clickRepository, rollupRepository, clickBroker, uniqueVisitors, botFilter, geoIP)
 
-	// Create controller
-	urlController := controllers.NewURLController(urlService, conf.BaseURL)
+	// Create import service
+	importService := services.NewImportService(urlService, importRepository, conf)
+
+	// Create backup service
+	backupService := services.NewBackupService(urlRepository, revisionRepository, rollupRepository, sequenceStore, cacheService, conf)
+
+	// Run a command instead of the server when asked
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "import":
+			err = runImportCommand(importService, os.Args[2:])
+		case "backup":
+			err = runBackupCommand(backupService, os.Args[2:])
+		case "restore":
+			err = runRestoreCommand(backupService, os.Args[2:])
+		default:
+			log.Fatalf("Unknown command %q; expected import, backup or restore", os.Args[1])
+		}
+		if err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	services.NewScheduler(urlService, conf.SchedulerInterval).Start(jobsCtx)
+	services.NewTrashPurger(urlService, conf.TrashRetention, conf.CodeQuarantine, conf.PurgeInterval).Start(jobsCtx)
+	if metadataService != nil {
+		metadataService.Start(jobsCtx)
+	}
+	webhookService.Start(jobsCtx)
+	clickBroker.Start(jobsCtx)
+	importService.Start(jobsCtx)
+	if conf.ClickEventRetentionDays > 0 {
+		retention := time.Duration(conf.ClickEventRetentionDays) * 24 * time.Hour
+		services.NewClickCompactor(clickService, retention, conf.PurgeInterval).Start(jobsCtx)
+	}
+	if conf.HealthCheckConcurrency > 0 {
+		services.NewHealthChecker(urlRepository, cacheService, conf).Start(jobsCtx)
+	}
+
+	// Create controllers
+	urlController := controllers.NewURLController(urlService, redirectService, clickService, conf.BaseURL, proxies, conf.UniqueVisitorDays)
+	tagController := controllers.NewTagController(urlService)
+	webhookController := controllers.NewWebhookController(webhookService)
+	exportController := controllers.NewExportController(services.NewExportService(urlRepository, clickRepository), conf.AdminToken)
+	backupController := controllers.NewBackupController(backupService, conf.AdminToken)
+	importController := controllers.NewImportController(importService, conf.AdminToken)
+	liveController := controllers.NewLiveController(urlService, clickService, conf.AdminToken, conf.LiveHeartbeat)
 
 	// Create router
 	router := mux.NewRouter()
@@ -73,13 +196,65 @@ func main() {
 	// API routes
 	router.HandleFunc("/shorten", urlController.CreateURL).Methods("POST")
 	router.HandleFunc("/shorten", urlController.GetAllURLStats).Methods("GET")
+	router.HandleFunc("/shorten/trash", urlController.GetTrash).Methods("GET")
+	router.HandleFunc("/shorten/search", urlController.SearchURLs).Methods("GET")
 	router.HandleFunc("/shorten/{shortCode}", urlController.GetURL).Methods("GET")
 	router.HandleFunc("/shorten/{shortCode}", urlController.UpdateURL).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}", urlController.PatchURL).Methods("PATCH")
 	router.HandleFunc("/shorten/{shortCode}", urlController.DeleteURL).Methods("DELETE")
 	router.HandleFunc("/shorten/{shortCode}/stats", urlController.GetURLStats).Methods("GET")
+	router.HandleFunc("/shorten/{shortCode}/restore", urlController.RestoreURL).Methods("POST")
+	router.HandleFunc("/shorten/{shortCode}/history", urlController.GetHistory).Methods("GET")
+	router.HandleFunc("/shorten/{shortCode}/rollback/{revision:[0-9]+}", urlController.Rollback).Methods("POST")
+	router.HandleFunc("/shorten/{shortCode}/rules", urlController.UpdateRules).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}/variants", urlController.UpdateVariants).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}/schedule", urlController.UpdateSchedule).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}/redirect-options", urlController.UpdateRedirectOptions).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}/social-preview", urlController.UpdateSocialPreview).Methods("PUT")
+	router.HandleFunc("/shorten/{shortCode}/tags", tagController.AddTags).Methods("POST")
+	router.HandleFunc("/shorten/{shortCode}/tags/{tag}", tagController.RemoveTag).Methods("DELETE")
+	router.HandleFunc("/shorten/{shortCode}/live", liveController.StreamLink).Methods("GET")
+	router.HandleFunc("/shorten/{shortCode}/timeseries", urlController.GetTimeSeries).Methods("GET")
+
+	// Tag and folder routes
+	router.HandleFunc("/tags", tagController.GetTags).Methods("GET")
+	router.HandleFunc("/tags/{tag}", tagController.RenameTag).Methods("PUT")
+	router.HandleFunc("/tags/{tag}", tagController.DeleteTag).Methods("DELETE")
+	router.HandleFunc("/folders", tagController.GetFolders).Methods("GET")
+	router.HandleFunc("/folders/{folder}", tagController.RenameFolder).Methods("PUT")
+	router.HandleFunc("/folders/{folder}", tagController.DeleteFolder).Methods("DELETE")
+
+	// Webhook routes
+	router.HandleFunc("/webhooks", webhookController.CreateSubscription).Methods("POST")
+	router.HandleFunc("/webhooks", webhookController.GetSubscriptions).Methods("GET")
+	router.HandleFunc("/webhooks/dead-letters", webhookController.GetDeadLetters).Methods("GET")
+	router.HandleFunc("/webhooks/deliveries/{id}/retry", webhookController.RetryDelivery).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", webhookController.GetSubscription).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", webhookController.UpdateSubscription).Methods("PUT")
+	router.HandleFunc("/webhooks/{id}", webhookController.DeleteSubscription).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", webhookController.GetDeliveries).Methods("GET")
+
+	// Export routes
+	router.HandleFunc("/export/links", exportController.ExportLinks).Methods("GET")
+	router.HandleFunc("/export/clicks", exportController.ExportClicks).Methods("GET")
+
+	// Import routes
+	router.HandleFunc("/imports", importController.CreateImport).Methods("POST")
+	router.HandleFunc("/imports", importController.GetImports).Methods("GET")
+	router.HandleFunc("/imports/{id}", importController.GetImport).Methods("GET")
+	router.HandleFunc("/imports/{id}/cancel", importController.CancelImport).Methods("POST")
+	router.HandleFunc("/imports/{id}/resume", importController.ResumeImport).Methods("POST")
+
+	// Backup routes
+	router.HandleFunc("/backup", backupController.Backup).Methods("GET")
+	router.HandleFunc("/restore", backupController.Restore).Methods("POST")
+
+	// Live click stream for every link
+	router.HandleFunc("/live", liveController.StreamAll).Methods("GET")
 
 	// Redirect route
-	router.HandleFunc("/r/{shortCode}", urlController.RedirectURL).Methods("GET")
+	router.HandleFunc("/r/{shortCode}", urlController.RedirectURL).Methods("GET", "HEAD", "POST")
+	router.HandleFunc("/r/{shortCode}/{path:.*}", urlController.RedirectURL).Methods("GET", "HEAD", "POST")
 
 	// Start server
 	srv := &http.Server{
@@ -101,6 +276,7 @@ func main() {
 
 	<-quit
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
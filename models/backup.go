@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// Backup archive format
+const (
+	BackupFormat  = "url-shortener-backup"
+	BackupVersion = 1 // Raised when a change to the archive is not backward compatible
+)
+
+// BackupManifest describes a backup archive. It is the first file of the
+// archive and lists the checksum and record count of every other file.
+type BackupManifest struct {
+	Format    string       `json:"format"`
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Files     []BackupFile `json:"files"`
+	APIKeys   []string     `json:"apiKeys,omitempty"` // Fingerprints of the trusted API keys configured when the backup was made
+}
+
+// BackupFile describes one JSON Lines file of a backup archive
+type BackupFile struct {
+	Name    string `json:"name"`
+	Records int64  `json:"records"`
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"`
+}
+
+// BackupLink is a link as stored in a backup archive: the link's API
+// representation plus the fields the API does not show
+type BackupLink struct {
+	URL
+	ImportedFrom *BackupImportOrigin `json:"importedFrom,omitempty"`
+}
+
+// BackupImportOrigin is the import job and record a link was created from,
+// as stored in a backup archive
+type BackupImportOrigin struct {
+	Job    string `json:"job"`
+	Record int    `json:"record"`
+}
+
+// BackupRevision is a destination revision as stored in a backup archive
+type BackupRevision struct {
+	ID        string    `json:"id"`
+	URLID     string    `json:"urlId"`
+	ShortCode string    `json:"shortCode"`
+	Number    int       `json:"revision"`
+	OldURL    string    `json:"oldUrl"`
+	NewURL    string    `json:"newUrl"`
+	Actor     string    `json:"actor"`
+	ActorNote string    `json:"actorNote,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BackupRollup is a click rollup as stored in a backup archive
+type BackupRollup struct {
+	ShortCode   string           `json:"shortCode"`
+	Granularity string           `json:"granularity"`
+	Start       time.Time        `json:"start"`
+	Clicks      int64            `json:"clicks"`
+	Bots        int64            `json:"bots"`
+	Visitors    map[string]int32 `json:"visitors,omitempty"` // HyperLogLog registers, by index
+	Referrers   map[string]int64 `json:"referrers,omitempty"`
+	Countries   map[string]int64 `json:"countries,omitempty"`
+	Platforms   map[string]int64 `json:"platforms,omitempty"`
+}
+
+// BackupCounter is the position of a sequence as stored in a backup archive
+type BackupCounter struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// RestoreReport describes the restore of a backup archive
+type RestoreReport struct {
+	Version        int                 `json:"version"`
+	CreatedAt      time.Time           `json:"createdAt"` // When the backup was made
+	Applied        bool                `json:"applied"`   // False when the archive was only verified
+	Files          []RestoreFileReport `json:"files"`
+	Errors         []RestoreError      `json:"errors,omitempty"`         // First failures only
+	MissingAPIKeys []string            `json:"missingApiKeys,omitempty"` // Trusted in the backup but not configured here
+}
+
+// RestoreFileReport counts the records restored from one file of a backup archive
+type RestoreFileReport struct {
+	Name     string `json:"name"`
+	Records  int64  `json:"records"`
+	Restored int64  `json:"restored"`
+	Failed   int64  `json:"failed"`
+}
+
+// RestoreError describes a record that could not be restored
+type RestoreError struct {
+	File    string `json:"file"`
+	Record  int64  `json:"record"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ClickEvent describes one visit to a short link
+type ClickEvent struct {
+	ShortCode string    `json:"shortCode" bson:"short_code"`
+	Variant   string    `json:"variant,omitempty" bson:"variant,omitempty"`
+	Country   string    `json:"country,omitempty" bson:"country,omitempty"`
+	Platform  string    `json:"platform" bson:"platform"`
+	Referrer  string    `json:"referrer,omitempty" bson:"referrer,omitempty"` // Host of the referring page
+	Bot       bool      `json:"bot,omitempty" bson:"bot"`
+	Time      time.Time `json:"time" bson:"time"`
+}
+
+// UniqueVisitorStats holds approximate unique visitor counts of a link. A
+// visitor is identified per day only, so the daily counts are not additive
+// into a count of distinct people.
+type UniqueVisitorStats struct {
+	Today int64                 `json:"today"`
+	Daily []DailyUniqueVisitors `json:"daily"` // Most recent day first
+}
+
+// DailyUniqueVisitors holds the unique visitors of one UTC day
+type DailyUniqueVisitors struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
@@ -8,4 +8,30 @@ var (
 	ErrorGeneratingShortCode = errors.New("failed to generate unique short code")
 	ErrorURLNotFound         = errors.New("URL not found")
 	ErrorShortCodeExists     = errors.New("short code already exists")
+	ErrorInvalidRule         = errors.New("invalid targeting rule")
+	ErrorInvalidVariant      = errors.New("invalid variant")
+	ErrorInvalidSchedule     = errors.New("invalid schedule")
+	ErrorInvalidOptions      = errors.New("invalid redirect options")
+	ErrorInvalidAlias        = errors.New("alias must be 3-32 letters, digits, '-' or '_'")
+	ErrorBlockedAlias        = errors.New("alias contains a blocked word")
+	ErrorInvalidAlphabet     = errors.New("short code alphabet must have at least 2 distinct characters from A-Z, a-z, 0-9, '_' and '-'")
+	ErrorRevisionNotFound    = errors.New("revision not found")
+	ErrorRevisionConflict    = errors.New("failed to record revision")
+	ErrorVersionMismatch     = errors.New("URL has been modified since it was read")
+	ErrorInvalidMetadata     = errors.New("metadata keys must be non-empty and cannot contain '.' or '$'")
+	ErrorInvalidTag          = errors.New("tags must be 1-50 characters")
+	ErrorInvalidFolder       = errors.New("folder names must be at most 100 characters")
+	ErrorInvalidPreview      = errors.New("invalid social preview")
+	ErrorInvalidWebhook      = errors.New("invalid webhook subscription")
+	ErrorWebhookNotFound     = errors.New("webhook subscription not found")
+	ErrorDeliveryNotFound    = errors.New("webhook delivery not found")
+	ErrorInvalidSearch       = errors.New("search needs a query, a page of at least 1 and a limit of 1-100")
+	ErrorInvalidExport       = errors.New("export format must be csv, ndjson or parquet")
+	ErrorInvalidTimeSeries   = errors.New("granularity must be hour or day, with from before to and at most 1000 points")
+	ErrorInvalidImport       = errors.New("import format must be csv, json or ndjson and conflict skip, overwrite or rename")
+	ErrorImportTooLarge      = errors.New("import file is too large")
+	ErrorImportNotFound      = errors.New("import job not found")
+	ErrorImportState         = errors.New("import job cannot do that in its current state")
+	ErrorInvalidBackup       = errors.New("invalid backup archive")
+	ErrorBackupTooLarge      = errors.New("backup archive is too large")
 )
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// ExportFilter selects the links or clicks to export
+type ExportFilter struct {
+	From      time.Time // Inclusive; zero for no lower bound
+	To        time.Time // Exclusive; zero for no upper bound
+	ShortCode string    // Clicks of one link only; empty for every link
+}
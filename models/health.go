@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LinkHealth records the last check of a URL's destination
+type LinkHealth struct {
+	CheckedURL  string     `json:"checkedUrl" bson:"checked_url"` // Destination that was checked
+	StatusCode  int        `json:"statusCode,omitempty" bson:"status_code,omitempty"`
+	LatencyMs   int64      `json:"latencyMs" bson:"latency_ms"`
+	FinalURL    string     `json:"finalUrl,omitempty" bson:"final_url,omitempty"` // Where redirects ended up
+	Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+	Broken      bool       `json:"broken" bson:"broken"`
+	BrokenSince *time.Time `json:"brokenSince,omitempty" bson:"broken_since,omitempty"`
+	CheckedAt   time.Time  `json:"checkedAt" bson:"checked_at"`
+}
+
+// HealthNotification is posted to the health webhook when a link breaks or recovers
+type HealthNotification struct {
+	Event     string     `json:"event"` // link.broken or link.recovered
+	ShortCode string     `json:"shortCode"`
+	URL       string     `json:"url"`
+	Health    LinkHealth `json:"health"`
+}
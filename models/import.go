@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Import formats
+const (
+	ImportCSV    = "csv"
+	ImportJSON   = "json"
+	ImportNDJSON = "ndjson"
+)
+
+// Import conflict policies, applied when an imported short code is taken
+const (
+	ConflictSkip      = "skip"      // Keep the existing link
+	ConflictOverwrite = "overwrite" // Replace the existing link with the imported one
+	ConflictRename    = "rename"    // Import under a new short code
+)
+
+// Import job statuses
+const (
+	ImportPending     = "pending"
+	ImportRunning     = "running"
+	ImportInterrupted = "interrupted" // Stopped by a shutdown; continues where it left off
+	ImportCompleted   = "completed"
+	ImportFailed      = "failed"
+	ImportCancelled   = "cancelled"
+)
+
+// ImportOrigin records the import job and record a link was created from, so
+// records replayed after an interrupted run are not imported twice
+type ImportOrigin struct {
+	Job    primitive.ObjectID `bson:"job"`
+	Record int                `bson:"record"` // 1-based position in the file
+}
+
+// ImportRecord is one link read from an import file
+type ImportRecord struct {
+	ShortCode string
+	URL       string
+	Title     string
+	Notes     string
+	Tags      []string
+	Folder    string
+	CreatedAt *time.Time
+	Clicks    *int
+}
+
+// ImportJob tracks an import. Records are processed in file order and
+// Processed is saved as the job goes, so an interrupted or failed job
+// continues after the last saved record.
+type ImportJob struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Status      string             `json:"status" bson:"status"`
+	Source      string             `json:"source" bson:"source"` // Uploaded or local file name
+	Format      string             `json:"format" bson:"format"`
+	Conflict    string             `json:"conflict" bson:"conflict"`
+	DryRun      bool               `json:"dryRun" bson:"dry_run"`
+	Total       int                `json:"total" bson:"total"` // Records in the file; 0 until the job starts
+	Processed   int                `json:"processed" bson:"processed"`
+	Progress    float64            `json:"progress" bson:"-"` // Percentage of records processed
+	Created     int                `json:"created" bson:"created"`
+	Overwritten int                `json:"overwritten" bson:"overwritten"`
+	Renamed     int                `json:"renamed" bson:"renamed"`
+	Skipped     int                `json:"skipped" bson:"skipped"`
+	Failed      int                `json:"failed" bson:"failed"`
+	Errors      []ImportError      `json:"errors,omitempty" bson:"errors,omitempty"`   // First failures only
+	Renames     []ImportRename     `json:"renames,omitempty" bson:"renames,omitempty"` // First renames only
+	Error       string             `json:"error,omitempty" bson:"error,omitempty"`     // Why the job failed
+	CreatedAt   time.Time          `json:"createdAt" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updated_at"`
+	StartedAt   *time.Time         `json:"startedAt,omitempty" bson:"started_at,omitempty"`
+	FinishedAt  *time.Time         `json:"finishedAt,omitempty" bson:"finished_at,omitempty"`
+	FilePath    string             `json:"-" bson:"file_path"`
+	OwnsFile    bool               `json:"-" bson:"owns_file"` // Uploaded copy, deleted when the job completes
+	CLI         bool               `json:"cli" bson:"cli"`     // Run by the import command rather than the server
+	LockedUntil time.Time          `json:"-" bson:"locked_until"`
+}
+
+// ImportError describes a record that could not be imported
+type ImportError struct {
+	Record    int    `json:"record"` // 1-based position in the file
+	ShortCode string `json:"shortCode,omitempty"`
+	Message   string `json:"message"`
+}
+
+// ImportRename records a link imported under a new short code
+type ImportRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
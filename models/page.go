@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PageMetadata holds what was read from a URL's destination page
+type PageMetadata struct {
+	Title       string            `json:"title,omitempty" bson:"title,omitempty"`
+	Description string            `json:"description,omitempty" bson:"description,omitempty"`
+	FaviconURL  string            `json:"faviconUrl,omitempty" bson:"favicon_url,omitempty"`
+	OpenGraph   map[string]string `json:"openGraph,omitempty" bson:"open_graph,omitempty"` // og:* properties without the prefix
+	FetchedURL  string            `json:"fetchedUrl" bson:"fetched_url"`                   // Destination the metadata was read from
+	FetchedAt   time.Time         `json:"fetchedAt" bson:"fetched_at"`
+	Error       string            `json:"error,omitempty" bson:"error,omitempty"` // Why the last fetch failed
+}
@@ -0,0 +1,16 @@
+package models
+
+// Query forwarding modes for RedirectOptions.ForwardQuery
+const (
+	QueryForwardNone     = ""
+	QueryForwardMerge    = "merge"    // Incoming parameters are added; the destination's win on conflict
+	QueryForwardOverride = "override" // Incoming parameters replace the destination's
+)
+
+// RedirectOptions controls how a redirect request is carried over to the destination
+type RedirectOptions struct {
+	ForwardQuery  string            `json:"forwardQuery,omitempty" bson:"forward_query,omitempty"`
+	ForwardPath   bool              `json:"forwardPath,omitempty" bson:"forward_path,omitempty"`
+	UTMParams     map[string]string `json:"utmParams,omitempty" bson:"utm_params,omitempty"`
+	AlwaysPreview bool              `json:"alwaysPreview,omitempty" bson:"always_preview,omitempty"` // Show the interstitial preview on every visit
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actor identifies who made a change. Name is the fingerprint of the API key
+// the request was made with, "anonymous" without one, or the background job
+// that made it. Note is what the client said about itself and is not verified.
+type Actor struct {
+	Name string
+	Note string
+}
+
+// Revision records one change of a URL's destination
+type Revision struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	URLID     primitive.ObjectID `json:"-" bson:"url_id"`
+	ShortCode string             `json:"shortCode" bson:"short_code"`
+	Number    int                `json:"revision" bson:"revision"`
+	OldURL    string             `json:"oldUrl" bson:"old_url"`
+	NewURL    string             `json:"newUrl" bson:"new_url"`
+	Actor     string             `json:"actor" bson:"actor"`
+	ActorNote string             `json:"actorNote,omitempty" bson:"actor_note,omitempty"` // Sent by the client in X-Actor; not verified
+	CreatedAt time.Time          `json:"createdAt" bson:"created_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Rollup granularities
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// ClickRollup holds the aggregated clicks of a link over one hour or one UTC
+// day. Breakdowns count people only; bots are only counted in Bots.
+type ClickRollup struct {
+	ShortCode   string           `bson:"short_code"`
+	Granularity string           `bson:"granularity"`
+	Start       time.Time        `bson:"start"`
+	Clicks      int64            `bson:"clicks"`
+	Bots        int64            `bson:"bots"`
+	Visitors    map[string]int32 `bson:"visitors,omitempty"` // HyperLogLog registers of the visitors, by index
+	Referrers   map[string]int64 `bson:"referrers,omitempty"`
+	Countries   map[string]int64 `bson:"countries,omitempty"`
+	Platforms   map[string]int64 `bson:"platforms,omitempty"`
+}
+
+// TimeSeriesResponse represents a link's clicks over time
+type TimeSeriesResponse struct {
+	ShortCode   string            `json:"shortCode"`
+	Granularity string            `json:"granularity"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Clicks      int64             `json:"clicks"`
+	Bots        int64             `json:"bots"`
+	Uniques     int64             `json:"uniques"` // Sum of the estimated distinct visitors of each UTC day in the range
+	Points      []TimeSeriesPoint `json:"points"`
+	Referrers   []CountEntry      `json:"referrers"` // Most frequent first
+	Countries   []CountEntry      `json:"countries"`
+	Platforms   []CountEntry      `json:"platforms"`
+}
+
+// TimeSeriesPoint holds the clicks of one hour or day
+type TimeSeriesPoint struct {
+	Start   time.Time `json:"start"`
+	Clicks  int64     `json:"clicks"`
+	Bots    int64     `json:"bots"`
+	Uniques int64     `json:"uniques"` // Estimated distinct visitors in the period
+}
+
+// CountEntry is one row of a breakdown
+type CountEntry struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ScheduledChange switches a link to a new destination at a given time
+type ScheduledChange struct {
+	At      time.Time `json:"at" bson:"at"`
+	URL     string    `json:"url" bson:"url"`
+	Applied bool      `json:"applied" bson:"applied"`
+}
+
+// UpdateScheduleRequest is used to parse the request for replacing a link's schedule
+type UpdateScheduleRequest struct {
+	ActiveFrom       *time.Time        `json:"activeFrom"`
+	ScheduledChanges []ScheduledChange `json:"scheduledChanges"`
+}
+
+// UnavailableResponse describes what to serve for a link that is not active yet
+type UnavailableResponse struct {
+	StatusCode  int
+	Message     string
+	RedirectURL string // When set, visitors are redirected here instead
+}
@@ -0,0 +1,10 @@
+package models
+
+// SearchResponse represents a page of search results
+type SearchResponse struct {
+	Query   string             `json:"query"`
+	Page    int                `json:"page"`
+	Limit   int                `json:"limit"`
+	Total   int64              `json:"total"`
+	Results []URLStatsResponse `json:"results"`
+}
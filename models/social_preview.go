@@ -0,0 +1,8 @@
+package models
+
+// SocialPreview overrides what link-unfurling crawlers show for a URL
+type SocialPreview struct {
+	Title       string `json:"title,omitempty" bson:"title,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Image       string `json:"image,omitempty" bson:"image,omitempty"` // Absolute http(s) URL of the preview image
+}
@@ -0,0 +1,32 @@
+package models
+
+// URLFilter narrows down URL listings; empty fields match everything
+type URLFilter struct {
+	Tag    string
+	Folder string
+	Broken bool // Only URLs whose last health check failed
+}
+
+// TagStats represents the aggregate statistics of a tag
+type TagStats struct {
+	Tag    string `json:"tag"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+// FolderStats represents the aggregate statistics of a folder
+type FolderStats struct {
+	Folder string `json:"folder"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+// TagsRequest is used to parse the request for adding tags to a URL
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// RenameRequest is used to parse the request for renaming a tag or folder
+type RenameRequest struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// TargetingRule sends matching visitors to an alternative destination.
+// Every condition that is set must match; rules are evaluated in order
+// and the first match wins.
+type TargetingRule struct {
+	Platforms  []string    `json:"platforms,omitempty" bson:"platforms,omitempty"`
+	Languages  []string    `json:"languages,omitempty" bson:"languages,omitempty"`
+	Countries  []string    `json:"countries,omitempty" bson:"countries,omitempty"`
+	TimeWindow *TimeWindow `json:"timeWindow,omitempty" bson:"time_window,omitempty"`
+	URL        string      `json:"url" bson:"url"`
+}
+
+// TimeWindow is a daily time-of-day range such as 09:00-17:00.
+// A window whose end is before its start wraps past midnight.
+type TimeWindow struct {
+	Start    string `json:"start" bson:"start"`
+	End      string `json:"end" bson:"end"`
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+}
+
+// Visitor describes the request being redirected
+type Visitor struct {
+	IP              string
+	UserAgent       string
+	AcceptLanguage  string
+	Time            time.Time
+	AssignedVariant string // Variant remembered from a previous visit, if any
+	Bot             bool   // Request made by a bot rather than a person
+}
+
+// UpdateRulesRequest is used to parse the request for replacing targeting rules
+type UpdateRulesRequest struct {
+	Rules []TargetingRule `json:"rules"`
+}
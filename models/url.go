@@ -8,17 +8,49 @@ import (
 
 // URL represents a URL shortening record
 type URL struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	OriginalURL string             `json:"url" bson:"original_url"`
-	ShortCode   string             `json:"shortCode" bson:"short_code"`
-	AccessCount int                `json:"accessCount" bson:"access_count"`
-	CreatedAt   time.Time          `json:"createdAt" bson:"created_at"`
-	UpdatedAt   time.Time          `json:"updatedAt" bson:"updated_at"`
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OriginalURL      string             `json:"url" bson:"original_url"`
+	ShortCode        string             `json:"shortCode" bson:"short_code"`
+	Title            string             `json:"title,omitempty" bson:"title,omitempty"`
+	Description      string             `json:"description,omitempty" bson:"description,omitempty"`
+	Notes            string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	Metadata         map[string]string  `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Tags             []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Folder           string             `json:"folder,omitempty" bson:"folder,omitempty"`
+	Page             *PageMetadata      `json:"page,omitempty" bson:"page,omitempty"` // Fetched from the destination
+	Health           *LinkHealth        `json:"health,omitempty" bson:"health,omitempty"`
+	AccessCount      int                `json:"accessCount" bson:"access_count"` // Clicks by people
+	BotCount         int                `json:"botCount" bson:"bot_count"`       // Requests by bots, prefetches and HEAD requests
+	Version          int                `json:"version" bson:"version"`
+	Rules            []TargetingRule    `json:"rules,omitempty" bson:"rules,omitempty"`
+	Variants         []Variant          `json:"variants,omitempty" bson:"variants,omitempty"`
+	ActiveFrom       *time.Time         `json:"activeFrom,omitempty" bson:"active_from,omitempty"`
+	ScheduledChanges []ScheduledChange  `json:"scheduledChanges,omitempty" bson:"scheduled_changes,omitempty"`
+	RedirectOptions  *RedirectOptions   `json:"redirectOptions,omitempty" bson:"redirect_options,omitempty"`
+	SocialPreview    *SocialPreview     `json:"socialPreview,omitempty" bson:"social_preview,omitempty"`
+	CreatorKey       string             `json:"creatorKey,omitempty" bson:"creator_key,omitempty"` // Fingerprint of the API key that created the URL
+	ImportedFrom     *ImportOrigin      `json:"-" bson:"imported_from,omitempty"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"created_at"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updated_at"`
+	DeletedAt        *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
 
 // CreateURLRequest is used to parse the request for creating a URL
 type CreateURLRequest struct {
-	URL string `json:"url"`
+	URL              string            `json:"url"`
+	Alias            string            `json:"alias,omitempty"` // Custom short code; generated when empty
+	Title            string            `json:"title,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Notes            string            `json:"notes,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Folder           string            `json:"folder,omitempty"`
+	Rules            []TargetingRule   `json:"rules,omitempty"`
+	Variants         []Variant         `json:"variants,omitempty"`
+	ActiveFrom       *time.Time        `json:"activeFrom,omitempty"`
+	ScheduledChanges []ScheduledChange `json:"scheduledChanges,omitempty"`
+	RedirectOptions  *RedirectOptions  `json:"redirectOptions,omitempty"`
+	SocialPreview    *SocialPreview    `json:"socialPreview,omitempty"`
 }
 
 // UpdateURLRequest is used to parse the request for updating a URL
@@ -26,21 +58,62 @@ type UpdateURLRequest struct {
 	URL string `json:"url"`
 }
 
+// URLPatch is a parsed JSON Merge Patch (RFC 7396) for a URL.
+// Nil fields are left unchanged; empty strings clear a field.
+type URLPatch struct {
+	URL         *string
+	Title       *string
+	Description *string
+	Notes       *string
+	Tags        *[]string // Replaces all tags
+	Folder      *string
+	// Metadata entries are merged into the existing metadata; nil values remove a key
+	Metadata      map[string]*string
+	ClearMetadata bool
+}
+
 // URLResponse represents the response object for a URL
 type URLResponse struct {
-	ID        primitive.ObjectID `json:"id"`
-	URL       string             `json:"url"`
-	ShortCode string             `json:"shortCode"`
-	CreatedAt time.Time          `json:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt"`
+	ID               primitive.ObjectID `json:"id"`
+	URL              string             `json:"url"`
+	ShortCode        string             `json:"shortCode"`
+	Title            string             `json:"title,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Notes            string             `json:"notes,omitempty"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	Tags             []string           `json:"tags,omitempty"`
+	Folder           string             `json:"folder,omitempty"`
+	Page             *PageMetadata      `json:"page,omitempty"`
+	Health           *LinkHealth        `json:"health,omitempty"`
+	Version          int                `json:"version"`
+	Rules            []TargetingRule    `json:"rules,omitempty"`
+	Variants         []Variant          `json:"variants,omitempty"`
+	ActiveFrom       *time.Time         `json:"activeFrom,omitempty"`
+	ScheduledChanges []ScheduledChange  `json:"scheduledChanges,omitempty"`
+	RedirectOptions  *RedirectOptions   `json:"redirectOptions,omitempty"`
+	SocialPreview    *SocialPreview     `json:"socialPreview,omitempty"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+	DeletedAt        *time.Time         `json:"deletedAt,omitempty"`
 }
 
 // URLStatsResponse represents the response object for URL statistics
 type URLStatsResponse struct {
-	ID          primitive.ObjectID `json:"id"`
-	URL         string             `json:"url"`
-	ShortCode   string             `json:"shortCode"`
-	CreatedAt   time.Time          `json:"createdAt"`
-	UpdatedAt   time.Time          `json:"updatedAt"`
-	AccessCount int                `json:"accessCount"`
+	ID             primitive.ObjectID  `json:"id"`
+	URL            string              `json:"url"`
+	ShortCode      string              `json:"shortCode"`
+	Title          string              `json:"title,omitempty"`
+	Description    string              `json:"description,omitempty"`
+	Notes          string              `json:"notes,omitempty"`
+	Metadata       map[string]string   `json:"metadata,omitempty"`
+	Tags           []string            `json:"tags,omitempty"`
+	Folder         string              `json:"folder,omitempty"`
+	Page           *PageMetadata       `json:"page,omitempty"`
+	Health         *LinkHealth         `json:"health,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+	AccessCount    int                 `json:"accessCount"` // Includes bots with ?traffic=all
+	BotCount       int                 `json:"botCount"`
+	UniqueVisitors *UniqueVisitorStats `json:"uniqueVisitors,omitempty"` // Only on a single URL's statistics
+	Variants       []Variant           `json:"variants,omitempty"`
 }
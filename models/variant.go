@@ -0,0 +1,15 @@
+package models
+
+// Variant is a weighted alternative destination used for A/B tests.
+// Traffic is split in proportion to the weights of a link's variants.
+type Variant struct {
+	Name   string `json:"name" bson:"name"`
+	URL    string `json:"url" bson:"url"`
+	Weight int    `json:"weight" bson:"weight"`
+	Clicks int    `json:"clicks" bson:"clicks"`
+}
+
+// UpdateVariantsRequest is used to parse the request for replacing variants
+type UpdateVariantsRequest struct {
+	Variants []Variant `json:"variants"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook event types
+const (
+	EventLinkCreated        = "link.created"
+	EventLinkUpdated        = "link.updated"
+	EventLinkDeleted        = "link.deleted"
+	EventLinkRestored       = "link.restored"
+	EventLinkExpired        = "link.expired"
+	EventLinkClickMilestone = "link.click_milestone"
+)
+
+// EventTypes lists every event a webhook can subscribe to
+var EventTypes = []string{
+	EventLinkCreated,
+	EventLinkUpdated,
+	EventLinkDeleted,
+	EventLinkRestored,
+	EventLinkExpired,
+	EventLinkClickMilestone,
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead" // Gave up after the last retry
+)
+
+// WebhookSubscription is an endpoint notified of link events
+type WebhookSubscription struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	URL       string             `json:"url" bson:"url"`
+	Secret    string             `json:"secret,omitempty" bson:"secret"` // Signs payloads; only returned when the subscription is created
+	Events    []string           `json:"events" bson:"events"`           // Subscribed event types; empty means all
+	Active    bool               `json:"active" bson:"active"`
+	CreatedAt time.Time          `json:"createdAt" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updated_at"`
+}
+
+// WebhookSubscriptionRequest is used to parse the request for creating or updating a subscription
+type WebhookSubscriptionRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // Generated when empty
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"` // Defaults to true
+}
+
+// WebhookLink is the representation of a URL in event payloads
+type WebhookLink struct {
+	ID          primitive.ObjectID `json:"id"`
+	URL         string             `json:"url"`
+	ShortCode   string             `json:"shortCode"`
+	Title       string             `json:"title,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Folder      string             `json:"folder,omitempty"`
+	AccessCount int                `json:"accessCount"`
+	Version     int                `json:"version"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	DeletedAt   *time.Time         `json:"deletedAt,omitempty"`
+}
+
+// WebhookEvent is the payload posted to subscribers
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Link      WebhookLink `json:"link"`
+	Milestone int         `json:"milestone,omitempty"` // Clicks reached, for link.click_milestone
+	Purged    bool        `json:"purged,omitempty"`    // Whether link.deleted is for a link purged from the trash
+}
+
+// WebhookDelivery tracks sending one event to one subscription
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubscriptionID primitive.ObjectID `json:"subscriptionId" bson:"subscription_id"`
+	EventID        string             `json:"eventId" bson:"event_id"`
+	EventType      string             `json:"eventType" bson:"event_type"`
+	Payload        string             `json:"payload" bson:"payload"` // Exact JSON body that is signed and sent
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" bson:"next_attempt_at"`
+	LastStatusCode int                `json:"lastStatusCode,omitempty" bson:"last_status_code,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"last_error,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"created_at"`
+	DeliveredAt    *time.Time         `json:"deliveredAt,omitempty" bson:"delivered_at,omitempty"`
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClickRepository handles database operations for individual click events
+type ClickRepository struct {
+	collection *mongo.Collection
+}
+
+// NewClickRepository creates a new instance of ClickRepository
+func NewClickRepository(db *config.Database) *ClickRepository {
+	// Create indexes for exports over a time range, of every link or of one
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexModels := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: 1}}},
+		{Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "time", Value: 1}}},
+	}
+
+	_, err := db.DB.Collection("click_events").Indexes().CreateMany(ctx, indexModels)
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes on click_events: %v", err)
+	}
+
+	return &ClickRepository{
+		collection: db.DB.Collection("click_events"),
+	}
+}
+
+// InsertClick stores a click event
+func (r *ClickRepository) InsertClick(event models.ClickEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// EachClick calls fn for every click matching the filter, oldest first, reading
+// the clicks in batches so a large range is never held in memory. It stops at
+// the first error from fn; cancelling ctx stops it too.
+func (r *ClickRepository) EachClick(ctx context.Context, filter models.ExportFilter, fn func(models.ClickEvent) error) error {
+	query := bson.M{}
+	if timeRange := timeRangeFilter(filter); len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+	if filter.ShortCode != "" {
+		query["short_code"] = filter.ShortCode
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: 1}}).SetBatchSize(1000)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event models.ClickEvent
+		if err := cursor.Decode(&event); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// timeRangeFilter builds the bounds of an export's time range
+func timeRangeFilter(filter models.ExportFilter) bson.M {
+	timeRange := bson.M{}
+	if !filter.From.IsZero() {
+		timeRange["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timeRange["$lt"] = filter.To
+	}
+	return timeRange
+}
+
+// DeleteClicksBefore deletes the click events older than cutoff
+func (r *ClickRepository) DeleteClicksBefore(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"time": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CounterRepository handles named, monotonically increasing sequences
+type CounterRepository struct {
+	collection *mongo.Collection
+}
+
+// counter is the document holding the last value handed out by a sequence
+type counter struct {
+	Name  string `bson:"_id"`
+	Value int64  `bson:"value"`
+}
+
+// NewCounterRepository creates a new instance of CounterRepository
+func NewCounterRepository(db *config.Database) *CounterRepository {
+	return &CounterRepository{
+		collection: db.DB.Collection("counters"),
+	}
+}
+
+// Lease atomically reserves the next size values of a sequence
+// and returns the last value of the reserved range
+func (r *CounterRepository) Lease(name string, size uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$inc": bson.M{"value": int64(size)},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var c counter
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": name}, update, opts).Decode(&c)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(c.Value), nil
+}
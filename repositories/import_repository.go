@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ImportRepository handles database operations for import jobs
+type ImportRepository struct {
+	collection *mongo.Collection
+}
+
+// NewImportRepository creates a new instance of ImportRepository
+func NewImportRepository(db *config.Database) *ImportRepository {
+	// Create an index for finding the next job to run
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+	}
+
+	_, err := db.DB.Collection("import_jobs").Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		log.Printf("Warning: Failed to create index on import_jobs: %v", err)
+	}
+
+	return &ImportRepository{
+		collection: db.DB.Collection("import_jobs"),
+	}
+}
+
+// CreateJob stores a new import job
+func (r *ImportRepository) CreateJob(job models.ImportJob) (models.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, job)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return job, nil
+}
+
+// GetJob retrieves an import job by its ID
+func (r *ImportRepository) GetJob(id primitive.ObjectID) (models.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var job models.ImportJob
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ImportJob{}, models.ErrorImportNotFound
+		}
+		return models.ImportJob{}, err
+	}
+
+	return job, nil
+}
+
+// GetJobs retrieves the latest import jobs, newest first
+func (r *ImportRepository) GetJobs(limit int64) ([]models.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"errors": 0, "renames": 0})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []models.ImportJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// ClaimNextJob atomically takes the oldest server job that is waiting to run,
+// or whose runner stopped renewing its lease, and leases it until now+lease
+func (r *ImportRepository) ClaimNextJob(now time.Time, lease time.Duration) (models.ImportJob, bool, error) {
+	return r.claim(bson.M{"cli": false}, now, lease)
+}
+
+// ClaimJob atomically takes a specific job if it is waiting to run
+func (r *ImportRepository) ClaimJob(id primitive.ObjectID, now time.Time, lease time.Duration) (models.ImportJob, bool, error) {
+	return r.claim(bson.M{"_id": id}, now, lease)
+}
+
+// claim leases the oldest claimable job matching filter
+func (r *ImportRepository) claim(filter bson.M, now time.Time, lease time.Duration) (models.ImportJob, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter["$or"] = bson.A{
+		bson.M{"status": bson.M{"$in": bson.A{models.ImportPending, models.ImportInterrupted}}},
+		bson.M{"status": models.ImportRunning, "locked_until": bson.M{"$lt": now}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       models.ImportRunning,
+			"locked_until": now.Add(lease),
+			"updated_at":   now,
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ImportJob
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ImportJob{}, false, nil
+		}
+		return models.ImportJob{}, false, err
+	}
+
+	return job, true, nil
+}
+
+// SaveProgress stores the progress of a running job and renews its lease. It
+// returns false when the job is no longer running, because it was cancelled.
+func (r *ImportRepository) SaveProgress(job models.ImportJob, lockedUntil time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"total":        job.Total,
+			"processed":    job.Processed,
+			"created":      job.Created,
+			"overwritten":  job.Overwritten,
+			"renamed":      job.Renamed,
+			"skipped":      job.Skipped,
+			"failed":       job.Failed,
+			"errors":       job.Errors,
+			"renames":      job.Renames,
+			"started_at":   job.StartedAt,
+			"locked_until": lockedUntil,
+			"updated_at":   time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": job.ID, "status": models.ImportRunning}, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// FinishJob moves a running job to its final status, or to interrupted
+func (r *ImportRepository) FinishJob(id primitive.ObjectID, status string, jobErr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{
+		"status":       status,
+		"error":        jobErr,
+		"locked_until": time.Time{},
+		"updated_at":   now,
+	}
+	if status != models.ImportInterrupted {
+		set["finished_at"] = now
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "status": models.ImportRunning}, bson.M{"$set": set})
+	return err
+}
+
+// SetStatus moves a job from one of the given statuses to another
+func (r *ImportRepository) SetStatus(id primitive.ObjectID, from []string, to string) (models.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": to, "updated_at": now}
+	update := bson.M{"$set": set}
+	if to == models.ImportPending {
+		update["$unset"] = bson.M{"error": "", "finished_at": ""}
+	} else {
+		set["finished_at"] = now
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var job models.ImportJob
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id, "status": bson.M{"$in": from}}, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			if _, err := r.GetJob(id); err != nil {
+				return models.ImportJob{}, err
+			}
+			return models.ImportJob{}, models.ErrorImportState
+		}
+		return models.ImportJob{}, err
+	}
+
+	return job, nil
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+)
+
+// RedisCounterRepository handles named, monotonically increasing sequences in Redis
+type RedisCounterRepository struct {
+	cache *config.RedisCache
+}
+
+// NewRedisCounterRepository creates a new instance of RedisCounterRepository
+func NewRedisCounterRepository(cache *config.RedisCache) *RedisCounterRepository {
+	return &RedisCounterRepository{
+		cache: cache,
+	}
+}
+
+// Lease atomically reserves the next size values of a sequence
+// and returns the last value of the reserved range
+func (r *RedisCounterRepository) Lease(name string, size uint64) (uint64, error) {
+	if r.cache == nil || r.cache.Client == nil {
+		return 0, errors.New("redis is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	end, err := r.cache.Client.IncrBy(ctx, "counter:"+name, int64(size)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(end), nil
+}
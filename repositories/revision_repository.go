@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxRevisionAttempts bounds retries when concurrent changes race for a revision number
+const maxRevisionAttempts = 5
+
+// RevisionRepository handles database operations for destination revisions
+type RevisionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRevisionRepository creates a new instance of RevisionRepository
+func NewRevisionRepository(db *config.Database) *RevisionRepository {
+	// Create a unique index so each revision number is used once per URL
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "url_id", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := db.DB.Collection("url_revisions").Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		log.Printf("Warning: Failed to create unique index on url_revisions: %v", err)
+	}
+
+	return &RevisionRepository{
+		collection: db.DB.Collection("url_revisions"),
+	}
+}
+
+// AddRevision stores a destination change under the next revision number of the URL
+func (r *RevisionRepository) AddRevision(revision models.Revision) (models.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	revision.CreatedAt = time.Now()
+
+	for attempt := 0; attempt < maxRevisionAttempts; attempt++ {
+		// Find the latest revision number
+		var latest models.Revision
+		opts := options.FindOne().SetSort(bson.D{{Key: "revision", Value: -1}})
+		err := r.collection.FindOne(ctx, bson.M{"url_id": revision.URLID}, opts).Decode(&latest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return models.Revision{}, err
+		}
+		revision.Number = latest.Number + 1
+
+		result, err := r.collection.InsertOne(ctx, revision)
+		if err != nil {
+			// Another change took this number, try the next one
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return models.Revision{}, err
+		}
+
+		revision.ID = result.InsertedID.(primitive.ObjectID)
+		return revision, nil
+	}
+
+	return models.Revision{}, models.ErrorRevisionConflict
+}
+
+// GetRevisions retrieves all revisions of a URL, oldest first
+func (r *RevisionRepository) GetRevisions(urlID primitive.ObjectID) ([]models.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "revision", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"url_id": urlID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.Revision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision retrieves a single revision of a URL
+func (r *RevisionRepository) GetRevision(urlID primitive.ObjectID, number int) (models.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var revision models.Revision
+	err := r.collection.FindOne(ctx, bson.M{"url_id": urlID, "revision": number}).Decode(&revision)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Revision{}, models.ErrorRevisionNotFound
+		}
+		return models.Revision{}, err
+	}
+
+	return revision, nil
+}
+
+// EachRevision calls fn for every revision of every URL, oldest first,
+// stopping at the first error from fn
+func (r *RevisionRepository) EachRevision(ctx context.Context, fn func(models.Revision) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(1000)
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var revision models.Revision
+		if err := cursor.Decode(&revision); err != nil {
+			return err
+		}
+		if err := fn(revision); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// ReplaceRevision stores a revision exactly as given, replacing the revision with the same ID
+func (r *RevisionRepository) ReplaceRevision(revision models.Revision) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": revision.ID}, revision, options.Replace().SetUpsert(true))
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return models.ErrorRevisionConflict
+	}
+	return err
+}
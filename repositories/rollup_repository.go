@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rollupKeyReplacer escapes breakdown names, such as referrer hosts, that
+// cannot be used as field names: '.' would nest and a leading '$' is reserved
+var rollupKeyReplacer = strings.NewReplacer(".", "．", "$", "＄")
+
+// rollupKeyRestorer reverses rollupKeyReplacer
+var rollupKeyRestorer = strings.NewReplacer("．", ".", "＄", "$")
+
+// RollupRepository handles database operations for click rollups
+type RollupRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRollupRepository creates a new instance of RollupRepository
+func NewRollupRepository(db *config.Database) *RollupRepository {
+	// Create a unique index so each link has one rollup per hour and per day
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "short_code", Value: 1}, {Key: "granularity", Value: 1}, {Key: "start", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := db.DB.Collection("click_rollups").Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		log.Printf("Warning: Failed to create unique index on click_rollups: %v", err)
+	}
+
+	return &RollupRepository{
+		collection: db.DB.Collection("click_rollups"),
+	}
+}
+
+// AddClick adds a click to the hourly and daily rollups of its link, creating
+// them on the first click, and raises the visitor's HyperLogLog register to
+// rank unless rank is 0. Bots only increment the bot count.
+func (r *RollupRepository) AddClick(event models.ClickEvent, register int, rank uint8) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inc := bson.M{}
+	if event.Bot {
+		inc["bots"] = 1
+	} else {
+		inc["clicks"] = 1
+		inc["referrers."+rollupKeyReplacer.Replace(orDefault(event.Referrer, "direct"))] = 1
+		inc["countries."+rollupKeyReplacer.Replace(orDefault(event.Country, "unknown"))] = 1
+		inc["platforms."+rollupKeyReplacer.Replace(event.Platform)] = 1
+	}
+	update := bson.M{"$inc": inc}
+	if rank > 0 {
+		update["$max"] = bson.M{"visitors." + strconv.Itoa(register): int32(rank)}
+	}
+
+	periods := []struct {
+		granularity string
+		start       time.Time
+	}{
+		{models.GranularityHour, event.Time.UTC().Truncate(time.Hour)},
+		{models.GranularityDay, event.Time.UTC().Truncate(24 * time.Hour)},
+	}
+
+	for _, period := range periods {
+		filter := bson.M{"short_code": event.ShortCode, "granularity": period.granularity, "start": period.start}
+		opts := options.Update().SetUpsert(true)
+
+		_, err := r.collection.UpdateOne(ctx, filter, update, opts)
+		if err != nil && mongo.IsDuplicateKeyError(err) {
+			// Two first clicks raced to create this rollup; the retry finds it.
+			// Each rollup is retried on its own so the other is not counted twice.
+			_, err = r.collection.UpdateOne(ctx, filter, update, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRollups retrieves the rollups of a link starting in [from, to), oldest first
+func (r *RollupRepository) GetRollups(shortCode string, granularity string, from time.Time, to time.Time) ([]models.ClickRollup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"short_code":  shortCode,
+		"granularity": granularity,
+		"start":       bson.M{"$gte": from, "$lt": to},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rollups []models.ClickRollup
+	if err := cursor.All(ctx, &rollups); err != nil {
+		return nil, err
+	}
+
+	for i := range rollups {
+		rollups[i].Referrers = restoreRollupKeys(rollups[i].Referrers)
+		rollups[i].Countries = restoreRollupKeys(rollups[i].Countries)
+		rollups[i].Platforms = restoreRollupKeys(rollups[i].Platforms)
+	}
+
+	return rollups, nil
+}
+
+// EachRollup calls fn for every rollup of every link, stopping at the first error from fn
+func (r *RollupRepository) EachRollup(ctx context.Context, fn func(models.ClickRollup) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(1000)
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rollup models.ClickRollup
+		if err := cursor.Decode(&rollup); err != nil {
+			return err
+		}
+		rollup.Referrers = restoreRollupKeys(rollup.Referrers)
+		rollup.Countries = restoreRollupKeys(rollup.Countries)
+		rollup.Platforms = restoreRollupKeys(rollup.Platforms)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// ReplaceRollup stores a rollup exactly as given, replacing the rollup of the
+// same link, granularity and start
+func (r *RollupRepository) ReplaceRollup(rollup models.ClickRollup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rollup.Referrers = escapeRollupKeys(rollup.Referrers)
+	rollup.Countries = escapeRollupKeys(rollup.Countries)
+	rollup.Platforms = escapeRollupKeys(rollup.Platforms)
+
+	filter := bson.M{"short_code": rollup.ShortCode, "granularity": rollup.Granularity, "start": rollup.Start}
+	_, err := r.collection.ReplaceOne(ctx, filter, rollup, options.Replace().SetUpsert(true))
+	return err
+}
+
+// escapeRollupKeys escapes the names of a breakdown for storage
+func escapeRollupKeys(counts map[string]int64) map[string]int64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	escaped := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		escaped[rollupKeyReplacer.Replace(key)] += count
+	}
+	return escaped
+}
+
+// restoreRollupKeys unescapes the names of a breakdown
+func restoreRollupKeys(counts map[string]int64) map[string]int64 {
+	restored := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		restored[rollupKeyRestorer.Replace(key)] += count
+	}
+	return restored
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
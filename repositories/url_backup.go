@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EachStoredURL calls fn for every URL, including those in the trash, in the
+// order they were created. It reads the URLs in batches and stops at the
+// first error from fn; cancelling ctx stops it too.
+func (r *URLRepository) EachStoredURL(ctx context.Context, fn func(models.URL) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(1000)
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var url models.URL
+		if err := cursor.Decode(&url); err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// ReplaceStoredURL stores a URL exactly as given, replacing the URL with the same ID
+func (r *URLRepository) ReplaceStoredURL(url models.URL) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": url.ID}, url, options.Replace().SetUpsert(true))
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return models.ErrorShortCodeExists
+	}
+	return err
+}
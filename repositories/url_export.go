@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EachURL calls fn for every live URL created in the filter's time range,
+// oldest first. Unlike GetAllURLs it reads the URLs in batches, so exports of
+// any size use constant memory. It stops at the first error from fn;
+// cancelling ctx stops it too.
+func (r *URLRepository) EachURL(ctx context.Context, filter models.ExportFilter, fn func(models.URL) error) error {
+	query := bson.M{"deleted_at": nil}
+	if timeRange := timeRangeFilter(filter); len(timeRange) > 0 {
+		query["created_at"] = timeRange
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetBatchSize(1000)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var url models.URL
+		if err := cursor.Decode(&url); err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetURLsDueForHealthCheck retrieves up to limit live URLs that were never
+// checked or were last checked before cutoff, least recently checked first.
+// Changing a URL's destination clears its health, so it is checked again soon.
+func (r *URLRepository) GetURLsDueForHealthCheck(cutoff time.Time, limit int64) ([]models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"health.checked_at": nil},
+			bson.M{"health.checked_at": bson.M{"$lt": cutoff}},
+		},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"short_code": 1, "original_url": 1, "health": 1}).
+		SetSort(bson.D{{Key: "health.checked_at", Value: 1}}).
+		SetLimit(limit)
+
+	var urls []models.URL
+	if err := r.find(ctx, filter, opts, &urls); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// SetHealth stores the result of a health check, as long as the URL's
+// destination is still the one checked. Like page metadata, health is not
+// an edit, so neither updated_at nor the version change.
+func (r *URLRepository) SetHealth(shortCode string, health models.LinkHealth) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := liveFilter(shortCode)
+	filter["original_url"] = health.CheckedURL
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"health": health}}, opts).Decode(&url)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
+		return models.URL{}, err
+	}
+
+	return url, nil
+}
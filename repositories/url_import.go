@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertImportedURL stores an imported URL, keeping its creation date and
+// click count rather than starting afresh like CreateURL
+func (r *URLRepository) InsertImportedURL(url models.URL) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	url.UpdatedAt = time.Now()
+	if url.CreatedAt.IsZero() {
+		url.CreatedAt = url.UpdatedAt
+	}
+	url.Version = 1
+
+	result, err := r.collection.InsertOne(ctx, url)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.URL{}, models.ErrorShortCodeExists
+		}
+		return models.URL{}, err
+	}
+
+	url.ID = result.InsertedID.(primitive.ObjectID)
+	return url, nil
+}
+
+// GetImportedURL retrieves the URL created from a record of an import job,
+// deleted or not
+func (r *URLRepository) GetImportedURL(origin models.ImportOrigin) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"imported_from.job": origin.Job, "imported_from.record": origin.Record}
+
+	var url models.URL
+	err := r.collection.FindOne(ctx, filter).Decode(&url)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
+		return models.URL{}, err
+	}
+
+	return url, nil
+}
+
+// OverwriteImportedURL replaces a live URL's destination and details with an
+// imported URL's and also returns its previous destination. The creation
+// date and click count are only replaced when the import has them.
+func (r *URLRepository) OverwriteImportedURL(url models.URL, hasCreatedAt bool, hasClicks bool) (models.URL, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"original_url": url.OriginalURL,
+		"updated_at":   time.Now(),
+	}
+	unset := bson.M{"health": ""}
+	for field, value := range map[string]string{
+		"title":  url.Title,
+		"notes":  url.Notes,
+		"folder": url.Folder,
+	} {
+		if value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	if len(url.Tags) == 0 {
+		unset["tags"] = ""
+	} else {
+		set["tags"] = url.Tags
+	}
+	if hasCreatedAt {
+		set["created_at"] = url.CreatedAt
+	}
+	if hasClicks {
+		set["access_count"] = url.AccessCount
+	}
+
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Return the document as it was before the update to learn the previous destination
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var previous models.URL
+	err := r.collection.FindOneAndUpdate(ctx, liveFilter(url.ShortCode), update, opts).Decode(&previous)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, "", models.ErrorURLNotFound
+		}
+		return models.URL{}, "", err
+	}
+
+	updated, err := r.GetURLByShortCode(url.ShortCode)
+	if err != nil {
+		return models.URL{}, "", err
+	}
+	return updated, previous.OriginalURL, nil
+}
+
+// ShortCodeTaken reports whether a short code is used by any URL, including
+// URLs in the trash
+func (r *URLRepository) ShortCodeTaken(shortCode string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"short_code": shortCode}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -34,6 +34,22 @@ func NewURLRepository(db *config.Database) *URLRepository {
 		log.Printf("Warning: Failed to create unique index on short_code: %v", err)
 	}
 
+	// Index tags and folder for filtered listings, health checks for the checker,
+	// the searchable fields for search and import origins for resumed imports
+	_, err = db.DB.Collection("urls").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "folder", Value: 1}}},
+		{Keys: bson.D{{Key: "health.checked_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "imported_from.job", Value: 1}, {Key: "imported_from.record", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"imported_from": bson.M{"$exists": true}}),
+		},
+		searchIndex,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create secondary indexes: %v", err)
+	}
+
 	return &URLRepository{
 		collection: db.DB.Collection("urls"),
 	}
@@ -44,23 +60,14 @@ func (r *URLRepository) CreateURL(url models.URL) (models.URL, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Check if short code already exists
-	var existingURL models.URL
-	err := r.collection.FindOne(ctx, bson.M{"short_code": url.ShortCode}).Decode(&existingURL)
-	if err == nil {
-		return models.URL{}, models.ErrorShortCodeExists
-	} else if err != mongo.ErrNoDocuments {
-		log.Printf("Error checking for existing short code: %v", err)
-		return models.URL{}, err
-	}
-
 	url.CreatedAt = time.Now()
 	url.UpdatedAt = time.Now()
 	url.AccessCount = 0
+	url.Version = 1
 
+	// The unique index on short_code rejects duplicates in a single round trip
 	result, err := r.collection.InsertOne(ctx, url)
 	if err != nil {
-		// Check if the error is due to a duplicate key (race condition)
 		if mongo.IsDuplicateKeyError(err) {
 			return models.URL{}, models.ErrorShortCodeExists
 		}
@@ -78,7 +85,7 @@ func (r *URLRepository) GetURLByShortCode(shortCode string) (models.URL, error)
 	defer cancel()
 
 	var url models.URL
-	err := r.collection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&url)
+	err := r.collection.FindOne(ctx, liveFilter(shortCode)).Decode(&url)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return models.URL{}, models.ErrorURLNotFound
@@ -89,85 +96,577 @@ func (r *URLRepository) GetURLByShortCode(shortCode string) (models.URL, error)
 	return url, nil
 }
 
-// UpdateURL updates a URL in the database
-func (r *URLRepository) UpdateURL(shortCode string, originalURL string) (models.URL, error) {
+// UpdateURL updates the destination of a URL in a single atomic operation and
+// also returns its previous destination. When expectedVersion is set, the
+// update only applies if the URL is still at that version.
+func (r *URLRepository) UpdateURL(shortCode string, originalURL string, expectedVersion *int) (models.URL, string, error) {
+	destination := originalURL
+	return r.PatchURL(shortCode, models.URLPatch{URL: &destination}, expectedVersion)
+}
+
+// PatchURL applies a merge patch to a URL in a single atomic operation and
+// also returns its previous destination. When expectedVersion is set, the
+// patch only applies if the URL is still at that version.
+func (r *URLRepository) PatchURL(shortCode string, patch models.URLPatch, expectedVersion *int) (models.URL, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+	unset := bson.M{}
+
+	if patch.URL != nil {
+		set["original_url"] = *patch.URL
+		unset["health"] = ""
+	}
+	for field, value := range map[string]*string{
+		"title":       patch.Title,
+		"description": patch.Description,
+		"notes":       patch.Notes,
+	} {
+		if value == nil {
+			continue
+		}
+		if *value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = *value
+		}
+	}
+	if patch.Tags != nil {
+		if len(*patch.Tags) == 0 {
+			unset["tags"] = ""
+		} else {
+			set["tags"] = *patch.Tags
+		}
+	}
+	if patch.Folder != nil {
+		if *patch.Folder == "" {
+			unset["folder"] = ""
+		} else {
+			set["folder"] = *patch.Folder
+		}
+	}
+	if patch.ClearMetadata {
+		unset["metadata"] = ""
+	} else {
+		for key, value := range patch.Metadata {
+			if value == nil {
+				unset["metadata."+key] = ""
+			} else {
+				set["metadata."+key] = *value
+			}
+		}
+	}
+
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Return the document as it was before the update to learn the previous destination
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
 	var url models.URL
-	err := r.collection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&url)
+	err := r.collection.FindOneAndUpdate(ctx, versionFilter(shortCode, expectedVersion), update, opts).Decode(&url)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return models.URL{}, models.ErrorURLNotFound
+			return models.URL{}, "", r.notFoundOrMismatch(ctx, shortCode, expectedVersion)
 		}
-		return models.URL{}, err
+		return models.URL{}, "", err
 	}
 
+	previousURL := url.OriginalURL
+	applyPatch(&url, patch)
+	url.UpdatedAt = now
+	url.Version++
+
+	return url, previousURL, nil
+}
+
+// applyPatch applies a merge patch to a URL in memory, mirroring PatchURL
+func applyPatch(url *models.URL, patch models.URLPatch) {
+	if patch.URL != nil {
+		url.OriginalURL = *patch.URL
+	}
+	if patch.Title != nil {
+		url.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		url.Description = *patch.Description
+	}
+	if patch.Notes != nil {
+		url.Notes = *patch.Notes
+	}
+	if patch.Tags != nil {
+		url.Tags = *patch.Tags
+		if len(url.Tags) == 0 {
+			url.Tags = nil
+		}
+	}
+	if patch.Folder != nil {
+		url.Folder = *patch.Folder
+	}
+	if patch.ClearMetadata {
+		url.Metadata = nil
+	}
+	for key, value := range patch.Metadata {
+		if value == nil {
+			delete(url.Metadata, key)
+			continue
+		}
+		if url.Metadata == nil {
+			url.Metadata = make(map[string]string)
+		}
+		url.Metadata[key] = *value
+	}
+	if len(url.Metadata) == 0 {
+		url.Metadata = nil
+	}
+}
+
+// UpdateRules replaces the targeting rules of a URL in the database
+func (r *URLRepository) UpdateRules(shortCode string, rules []models.TargetingRule) (models.URL, error) {
+	return r.setFields(shortCode, bson.M{"rules": rules})
+}
+
+// UpdateVariants replaces the A/B variants of a URL in the database. Variants
+// that keep their name keep their click count, which is read inside the same
+// write so that clicks recorded meanwhile are not lost.
+func (r *URLRepository) UpdateVariants(shortCode string, variants []models.Variant) (models.URL, error) {
+	// Clicks of the stored variant with the same name as $$v, or 0
+	storedClicks := bson.M{"$ifNull": bson.A{
+		bson.M{"$arrayElemAt": bson.A{
+			bson.M{"$map": bson.M{
+				"input": bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$variants", bson.A{}}},
+					"as":    "stored",
+					"cond":  bson.M{"$eq": bson.A{"$$stored.name", "$$v.name"}},
+				}},
+				"as": "stored",
+				"in": "$$stored.clicks",
+			}},
+			0,
+		}},
+		0,
+	}}
+
+	// An update pipeline, so the bookkeeping of withBookkeeping is spelled out
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"variants": bson.M{"$map": bson.M{
+			"input": bson.M{"$literal": variants},
+			"as":    "v",
+			"in":    bson.M{"$mergeObjects": bson.A{"$$v", bson.M{"clicks": storedClicks}}},
+		}},
+		"updated_at": time.Now(),
+		"version":    bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+	}}}}
+
+	return r.findAndUpdate(shortCode, update)
+}
+
+// UpdateSchedule replaces the activation time and scheduled changes of a URL in the database
+func (r *URLRepository) UpdateSchedule(shortCode string, activeFrom *time.Time, changes []models.ScheduledChange) (models.URL, error) {
+	return r.setFields(shortCode, bson.M{
+		"active_from":       activeFrom,
+		"scheduled_changes": changes,
+	})
+}
+
+// UpdateRedirectOptions replaces the redirect options of a URL in the database
+func (r *URLRepository) UpdateRedirectOptions(shortCode string, redirectOptions *models.RedirectOptions) (models.URL, error) {
+	return r.setFields(shortCode, bson.M{"redirect_options": redirectOptions})
+}
+
+// UpdateSocialPreview replaces the social preview of a URL; nil removes it
+func (r *URLRepository) UpdateSocialPreview(shortCode string, preview *models.SocialPreview) (models.URL, error) {
+	if preview == nil {
+		return r.modify(shortCode, bson.M{"$unset": bson.M{"social_preview": ""}})
+	}
+	return r.setFields(shortCode, bson.M{"social_preview": preview})
+}
+
+// GetURLsWithDueChanges retrieves URLs that have unapplied scheduled changes due at now
+func (r *URLRepository) GetURLsWithDueChanges(now time.Time) ([]models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"deleted_at": nil,
+		"scheduled_changes": bson.M{
+			"$elemMatch": bson.M{
+				"applied": false,
+				"at":      bson.M{"$lte": now},
+			},
+		},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var urls []models.URL
+	if err := cursor.All(ctx, &urls); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// ApplyScheduledChange sets a URL's destination and marks every change due at now as applied
+func (r *URLRepository) ApplyScheduledChange(shortCode string, originalURL string, now time.Time) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	update := bson.M{
 		"$set": bson.M{
-			"original_url": originalURL,
-			"updated_at":   time.Now(),
+			"original_url":                     originalURL,
+			"updated_at":                       now,
+			"scheduled_changes.$[due].applied": true,
 		},
+		"$unset": bson.M{"health": ""},
+		"$inc":   bson.M{"version": 1},
 	}
 
-	_, err = r.collection.UpdateOne(ctx, bson.M{"short_code": shortCode}, update)
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{
+				"due.applied": false,
+				"due.at":      bson.M{"$lte": now},
+			}},
+		})
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, liveFilter(shortCode), update, opts).Decode(&url)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
 		return models.URL{}, err
 	}
 
-	// Fetch updated document
-	err = r.collection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&url)
+	return url, nil
+}
+
+// SetPageMetadata stores the metadata fetched from a URL's destination, as
+// long as the destination is still fetchedURL. Page metadata is not an edit,
+// so neither updated_at nor the version change.
+func (r *URLRepository) SetPageMetadata(shortCode string, fetchedURL string, page models.PageMetadata) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := liveFilter(shortCode)
+	filter["original_url"] = fetchedURL
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"page": page}}, opts).Decode(&url)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
 		return models.URL{}, err
 	}
 
 	return url, nil
 }
 
-// DeleteURL deletes a URL from the database
-func (r *URLRepository) DeleteURL(shortCode string) error {
+// setFields sets the given fields on a URL, bumps updated_at and the version
+// and returns the updated document
+func (r *URLRepository) setFields(shortCode string, fields bson.M) (models.URL, error) {
+	return r.modify(shortCode, bson.M{"$set": fields})
+}
+
+// modify applies an update to a URL, bumps updated_at and the version
+// and returns the updated document
+func (r *URLRepository) modify(shortCode string, update bson.M) (models.URL, error) {
+	return r.findAndUpdate(shortCode, withBookkeeping(update))
+}
+
+// findAndUpdate applies an update document or pipeline to a live URL and
+// returns the updated document
+func (r *URLRepository) findAndUpdate(shortCode string, update interface{}) (models.URL, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := r.collection.DeleteOne(ctx, bson.M{"short_code": shortCode})
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, liveFilter(shortCode), update, opts).Decode(&url)
 	if err != nil {
-		return err
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
+		return models.URL{}, err
+	}
+
+	return url, nil
+}
+
+// withBookkeeping adds the updated_at and version changes every edit makes to an update
+func withBookkeeping(update bson.M) bson.M {
+	set := bson.M{"updated_at": time.Now()}
+	if fields, ok := update["$set"].(bson.M); ok {
+		for key, value := range fields {
+			set[key] = value
+		}
+	}
+
+	result := bson.M{}
+	for operator, fields := range update {
+		result[operator] = fields
+	}
+	result["$set"] = set
+	result["$inc"] = bson.M{"version": 1}
+	return result
+}
+
+// liveFilter matches a URL by short code unless it has been deleted
+func liveFilter(shortCode string) bson.M {
+	return bson.M{"short_code": shortCode, "deleted_at": nil}
+}
+
+// versionFilter matches a live URL by short code and, when expectedVersion
+// is set, by version. URLs stored before versioning count as version 0.
+func versionFilter(shortCode string, expectedVersion *int) bson.M {
+	filter := liveFilter(shortCode)
+	if expectedVersion != nil {
+		if *expectedVersion == 0 {
+			filter["version"] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			filter["version"] = *expectedVersion
+		}
 	}
+	return filter
+}
 
-	if result.DeletedCount == 0 {
+// notFoundOrMismatch explains why a versioned update matched nothing
+func (r *URLRepository) notFoundOrMismatch(ctx context.Context, shortCode string, expectedVersion *int) error {
+	if expectedVersion == nil {
 		return models.ErrorURLNotFound
 	}
 
-	return nil
+	count, err := r.collection.CountDocuments(ctx, liveFilter(shortCode))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return models.ErrorURLNotFound
+	}
+	return models.ErrorVersionMismatch
 }
 
-// IncrementAccessCount increments the access count for a URL
-func (r *URLRepository) IncrementAccessCount(shortCode string) error {
+// DeleteURL moves a URL to the trash and returns it. When expectedVersion is set, the URL
+// is only deleted if it is still at that version.
+func (r *URLRepository) DeleteURL(shortCode string, expectedVersion *int) (models.URL, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	update := bson.M{
-		"$inc": bson.M{"access_count": 1},
+		"$set": bson.M{"deleted_at": time.Now()},
+		"$inc": bson.M{"version": 1},
 	}
 
-	result, err := r.collection.UpdateOne(ctx, bson.M{"short_code": shortCode}, update)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, versionFilter(shortCode, expectedVersion), update, opts).Decode(&url)
 	if err != nil {
-		return err
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, r.notFoundOrMismatch(ctx, shortCode, expectedVersion)
+		}
+		return models.URL{}, err
 	}
 
-	if result.ModifiedCount == 0 {
+	return url, nil
+}
+
+// RestoreURL moves a URL out of the trash
+func (r *URLRepository) RestoreURL(shortCode string) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"short_code": shortCode,
+		"deleted_at": bson.M{"$ne": nil},
+		"purged":     bson.M{"$ne": true},
+	}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&url)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return models.URL{}, models.ErrorShortCodeExists
+		}
+		return models.URL{}, err
+	}
+
+	return url, nil
+}
+
+// GetDeletedURLs retrieves all URLs in the trash
+func (r *URLRepository) GetDeletedURLs() ([]models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"deleted_at": bson.M{"$ne": nil},
+		"purged":     bson.M{"$ne": true},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var urls []models.URL
+	if err := cursor.All(ctx, &urls); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// PurgeDeletedURLs hard-deletes URLs that were moved to the trash before
+// retentionCutoff. URLs still inside their quarantine, i.e. deleted after
+// quarantineCutoff, are reduced to a tombstone that only keeps the short
+// code reserved; tombstones are removed once their quarantine ends.
+// It returns the URLs purged, as they were before purging, and the number
+// of URLs purged or released.
+func (r *URLRepository) PurgeDeletedURLs(retentionCutoff, quarantineCutoff time.Time) ([]models.URL, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Collect the URLs leaving the trash first so their deletion can be announced
+	expiredFilter := bson.M{
+		"deleted_at": bson.M{"$lt": retentionCutoff},
+		"purged":     bson.M{"$ne": true},
+	}
+	cursor, err := r.collection.Find(ctx, expiredFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var purged []models.URL
+	if err := cursor.All(ctx, &purged); err != nil {
+		return nil, 0, err
+	}
+
+	// Remove everything past both retention and quarantine, including tombstones
+	deleteFilter := bson.M{
+		"deleted_at": bson.M{"$lt": quarantineCutoff},
+		"$or": bson.A{
+			bson.M{"purged": true},
+			bson.M{"deleted_at": bson.M{"$lt": retentionCutoff}},
+		},
+	}
+	deleted, err := r.collection.DeleteMany(ctx, deleteFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Reduce the rest of the expired trash to tombstones
+	tombstone := bson.A{
+		bson.M{"$replaceWith": bson.M{
+			"_id":        "$_id",
+			"short_code": "$short_code",
+			"deleted_at": "$deleted_at",
+			"purged":     true,
+		}},
+	}
+	tombstoned, err := r.collection.UpdateMany(ctx, expiredFilter, tombstone)
+	if err != nil {
+		return purged, deleted.DeletedCount, err
+	}
+
+	return purged, deleted.DeletedCount + tombstoned.ModifiedCount, nil
+}
+
+// IncrementAccessCount increments the access count for a URL, and the click
+// count of the served variant if one is given, and returns the updated URL
+func (r *URLRepository) IncrementAccessCount(shortCode string, variant string) (models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	inc := bson.M{"access_count": 1}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if variant != "" {
+		inc["variants.$[v].clicks"] = 1
+		opts.SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"v.name": variant}},
+		})
+	}
+
+	update := bson.M{
+		"$inc": inc,
+	}
+
+	var url models.URL
+	err := r.collection.FindOneAndUpdate(ctx, liveFilter(shortCode), update, opts).Decode(&url)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URL{}, models.ErrorURLNotFound
+		}
+		return models.URL{}, err
+	}
+
+	return url, nil
+}
+
+// IncrementBotCount increments the bot request count for a URL
+func (r *URLRepository) IncrementBotCount(shortCode string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$inc": bson.M{"bot_count": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, liveFilter(shortCode), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
 		return models.ErrorURLNotFound
 	}
 
 	return nil
 }
 
-// GetAllURLs retrieves all URLs from the database
-func (r *URLRepository) GetAllURLs() ([]models.URL, error) {
+// GetAllURLs retrieves all URLs matching the filter from the database
+func (r *URLRepository) GetAllURLs(filter models.URLFilter) ([]models.URL, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	query := bson.M{"deleted_at": nil}
+	if filter.Tag != "" {
+		query["tags"] = filter.Tag
+	}
+	if filter.Folder != "" {
+		query["folder"] = filter.Folder
+	}
+	if filter.Broken {
+		query["health.broken"] = true
+	}
+
+	cursor, err := r.collection.Find(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// searchIndex is a text index over the fields searched by SearchURLs
+var searchIndex = mongo.IndexModel{
+	Keys: bson.D{
+		{Key: "original_url", Value: "text"},
+		{Key: "short_code", Value: "text"},
+		{Key: "title", Value: "text"},
+		{Key: "notes", Value: "text"},
+		{Key: "tags", Value: "text"},
+	},
+	Options: options.Index().
+		SetName("search").
+		// URLs and codes are not natural language, so skip stemming and stop words
+		SetDefaultLanguage("none").
+		SetWeights(bson.D{
+			{Key: "short_code", Value: 10},
+			{Key: "title", Value: 5},
+			{Key: "tags", Value: 5},
+			{Key: "original_url", Value: 3},
+			{Key: "notes", Value: 1},
+		}),
+}
+
+// codePrefixPattern matches queries that could be the start of a short code
+var codePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SearchURLs finds live URLs matching a query and returns one page of them
+// together with the total number of matches. Links whose short code starts
+// with the query, or whose destination is exactly the query, rank first in
+// short code order; the remaining matches come from the text index, ranked
+// by relevance.
+func (r *URLRepository) SearchURLs(query string, skip, limit int64) ([]models.URL, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	direct := bson.A{bson.M{"original_url": query}}
+	if codePrefixPattern.MatchString(query) {
+		direct = append(direct, bson.M{"short_code": bson.M{"$regex": "^" + regexp.QuoteMeta(query)}})
+	}
+
+	directFilter := bson.M{"deleted_at": nil, "$or": direct}
+	textFilter := bson.M{"deleted_at": nil, "$text": bson.M{"$search": query}, "$nor": direct}
+
+	directTotal, err := r.collection.CountDocuments(ctx, directFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	textTotal, err := r.collection.CountDocuments(ctx, textFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var urls []models.URL
+
+	// Direct matches fill the page first
+	if skip < directTotal {
+		opts := options.Find().
+			SetSort(bson.D{{Key: "short_code", Value: 1}}).
+			SetSkip(skip).
+			SetLimit(limit)
+		if err := r.find(ctx, directFilter, opts, &urls); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Text matches fill the rest
+	if remaining := limit - int64(len(urls)); remaining > 0 {
+		textSkip := skip - directTotal
+		if textSkip < 0 {
+			textSkip = 0
+		}
+		score := bson.M{"$meta": "textScore"}
+		opts := options.Find().
+			SetProjection(bson.M{"score": score}).
+			SetSort(bson.D{{Key: "score", Value: score}, {Key: "short_code", Value: 1}}).
+			SetSkip(textSkip).
+			SetLimit(remaining)
+
+		var textURLs []models.URL
+		if err := r.find(ctx, textFilter, opts, &textURLs); err != nil {
+			return nil, 0, err
+		}
+		urls = append(urls, textURLs...)
+	}
+
+	return urls, directTotal + textTotal, nil
+}
+
+// find runs a query on the URL collection and decodes every result
+func (r *URLRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions, results *[]models.URL) error {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddTags adds tags to a URL, ignoring ones it already has
+func (r *URLRepository) AddTags(shortCode string, tags []string) (models.URL, error) {
+	return r.modify(shortCode, bson.M{
+		"$addToSet": bson.M{"tags": bson.M{"$each": tags}},
+	})
+}
+
+// RemoveTag removes a tag from a URL
+func (r *URLRepository) RemoveTag(shortCode string, tag string) (models.URL, error) {
+	return r.modify(shortCode, bson.M{
+		"$pull": bson.M{"tags": tag},
+	})
+}
+
+// RenameTag renames a tag on every URL and returns the short codes of the URLs
+// changed. The tags must differ, or the tag is removed from every URL.
+func (r *URLRepository) RenameTag(oldTag, newTag string) ([]string, error) {
+	filter := bson.M{"deleted_at": nil, "tags": oldTag}
+
+	// Add the new tag before pulling the old one so URLs having both end up with one copy
+	return r.modifyMany(filter,
+		bson.M{"$addToSet": bson.M{"tags": newTag}},
+		bson.M{"$pull": bson.M{"tags": oldTag}},
+	)
+}
+
+// DeleteTag removes a tag from every URL and returns the short codes of the URLs changed
+func (r *URLRepository) DeleteTag(tag string) ([]string, error) {
+	filter := bson.M{"deleted_at": nil, "tags": tag}
+	return r.modifyMany(filter, bson.M{"$pull": bson.M{"tags": tag}})
+}
+
+// RenameFolder moves every URL in a folder to another and returns the short codes of the URLs changed
+func (r *URLRepository) RenameFolder(oldFolder, newFolder string) ([]string, error) {
+	filter := bson.M{"deleted_at": nil, "folder": oldFolder}
+	return r.modifyMany(filter, bson.M{"$set": bson.M{"folder": newFolder}})
+}
+
+// DeleteFolder takes every URL out of a folder and returns the short codes of the URLs changed
+func (r *URLRepository) DeleteFolder(folder string) ([]string, error) {
+	filter := bson.M{"deleted_at": nil, "folder": folder}
+	return r.modifyMany(filter, bson.M{"$unset": bson.M{"folder": ""}})
+}
+
+// GetTagStats aggregates link counts and click totals per tag
+func (r *URLRepository) GetTagStats() ([]models.TagStats, error) {
+	pipeline := groupByPipeline("tags", true)
+
+	var stats []struct {
+		Tag    string `bson:"_id"`
+		Links  int    `bson:"links"`
+		Clicks int    `bson:"clicks"`
+	}
+	if err := r.aggregate(pipeline, &stats); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.TagStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, models.TagStats{Tag: s.Tag, Links: s.Links, Clicks: s.Clicks})
+	}
+	return result, nil
+}
+
+// GetFolderStats aggregates link counts and click totals per folder
+func (r *URLRepository) GetFolderStats() ([]models.FolderStats, error) {
+	pipeline := groupByPipeline("folder", false)
+
+	var stats []struct {
+		Folder string `bson:"_id"`
+		Links  int    `bson:"links"`
+		Clicks int    `bson:"clicks"`
+	}
+	if err := r.aggregate(pipeline, &stats); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.FolderStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, models.FolderStats{Folder: s.Folder, Links: s.Links, Clicks: s.Clicks})
+	}
+	return result, nil
+}
+
+// groupByPipeline counts live URLs and sums their clicks per value of a
+// field, unwinding the field first when it is an array
+func groupByPipeline(field string, unwind bool) bson.A {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"deleted_at": nil, field: bson.M{"$exists": true, "$ne": ""}}},
+	}
+	if unwind {
+		pipeline = append(pipeline, bson.M{"$unwind": "$" + field})
+	}
+	return append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":    "$" + field,
+			"links":  bson.M{"$sum": 1},
+			"clicks": bson.M{"$sum": "$access_count"},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	)
+}
+
+// aggregate runs a pipeline on the URL collection and decodes every result
+func (r *URLRepository) aggregate(pipeline bson.A, results interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
+
+// GetURLsByShortCodes retrieves the live URLs with the given short codes
+func (r *URLRepository) GetURLsByShortCodes(shortCodes []string) ([]models.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"short_code": bson.M{"$in": shortCodes}, "deleted_at": nil}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var urls []models.URL
+	if err := cursor.All(ctx, &urls); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// modifyMany applies updates in order to every URL matching the filter,
+// bumping updated_at and the version, and returns the short codes of the URLs matched
+func (r *URLRepository) modifyMany(filter bson.M, updates ...bson.M) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Collect the affected short codes first so their cache entries can be invalidated
+	opts := options.Find().SetProjection(bson.M{"short_code": 1})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.URL
+	if err := cursor.All(ctx, &matched); err != nil {
+		return nil, err
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	shortCodes := make([]string, 0, len(matched))
+	for _, url := range matched {
+		shortCodes = append(shortCodes, url.ShortCode)
+	}
+
+	scope := bson.M{"short_code": bson.M{"$in": shortCodes}, "deleted_at": nil}
+	for _, update := range updates {
+		if _, err := r.collection.UpdateMany(ctx, scope, withBookkeeping(update)); err != nil {
+			return shortCodes, err
+		}
+	}
+
+	return shortCodes, nil
+}
@@ -0,0 +1,303 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deliveryLogRetention is how long successful deliveries are kept in the delivery log
+const deliveryLogRetention = 30 * 24 * time.Hour
+
+// WebhookRepository handles database operations for webhook subscriptions and deliveries
+type WebhookRepository struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository
+func NewWebhookRepository(db *config.Database) *WebhookRepository {
+	// Index deliveries for the delivery worker and the delivery log, and expire
+	// successful ones once they are old
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.DB.Collection("webhook_deliveries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "delivered_at", Value: 1}},
+			Options: options.Index().
+				SetExpireAfterSeconds(int32(deliveryLogRetention.Seconds())).
+				SetPartialFilterExpression(bson.M{"status": models.DeliverySucceeded}),
+		},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes on webhook_deliveries: %v", err)
+	}
+
+	return &WebhookRepository{
+		subscriptions: db.DB.Collection("webhook_subscriptions"),
+		deliveries:    db.DB.Collection("webhook_deliveries"),
+	}
+}
+
+// CreateSubscription stores a new subscription
+func (r *WebhookRepository) CreateSubscription(subscription models.WebhookSubscription) (models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subscription.CreatedAt = time.Now()
+	subscription.UpdatedAt = subscription.CreatedAt
+
+	result, err := r.subscriptions.InsertOne(ctx, subscription)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	subscription.ID = result.InsertedID.(primitive.ObjectID)
+	return subscription, nil
+}
+
+// GetSubscriptions retrieves every subscription, oldest first
+func (r *WebhookRepository) GetSubscriptions() ([]models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.subscriptions.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subscriptions []models.WebhookSubscription
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// GetActiveSubscriptions retrieves the active subscriptions to an event type
+func (r *WebhookRepository) GetActiveSubscriptions(eventType string) ([]models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"active": true,
+		"$or": bson.A{
+			bson.M{"events": eventType},
+			bson.M{"events": bson.M{"$size": 0}},
+		},
+	}
+
+	cursor, err := r.subscriptions.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subscriptions []models.WebhookSubscription
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// GetSubscription retrieves a subscription by its ID
+func (r *WebhookRepository) GetSubscription(id primitive.ObjectID) (models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var subscription models.WebhookSubscription
+	err := r.subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&subscription)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.WebhookSubscription{}, models.ErrorWebhookNotFound
+		}
+		return models.WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+// UpdateSubscription replaces the URL, events, active flag and, when
+// non-empty, the secret of a subscription
+func (r *WebhookRepository) UpdateSubscription(subscription models.WebhookSubscription) (models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"url":        subscription.URL,
+		"events":     subscription.Events,
+		"active":     subscription.Active,
+		"updated_at": time.Now(),
+	}
+	if subscription.Secret != "" {
+		set["secret"] = subscription.Secret
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.WebhookSubscription
+	err := r.subscriptions.FindOneAndUpdate(ctx, bson.M{"_id": subscription.ID}, bson.M{"$set": set}, opts).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.WebhookSubscription{}, models.ErrorWebhookNotFound
+		}
+		return models.WebhookSubscription{}, err
+	}
+
+	return updated, nil
+}
+
+// DeleteSubscription removes a subscription and its pending deliveries
+func (r *WebhookRepository) DeleteSubscription(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.subscriptions.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrorWebhookNotFound
+	}
+
+	_, err = r.deliveries.DeleteMany(ctx, bson.M{"subscription_id": id, "status": models.DeliveryPending})
+	return err
+}
+
+// CreateDeliveries queues deliveries
+func (r *WebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	documents := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		documents[i] = delivery
+	}
+
+	_, err := r.deliveries.InsertMany(ctx, documents)
+	return err
+}
+
+// ClaimDueDelivery takes the next pending delivery due at now, counting the
+// attempt and hiding the delivery from other workers until now+lease so a
+// crashed worker's delivery is retried rather than lost
+func (r *WebhookRepository) ClaimDueDelivery(now time.Time, lease time.Duration) (models.WebhookDelivery, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"status":          models.DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.WebhookDelivery{}, false, nil
+		}
+		return models.WebhookDelivery{}, false, err
+	}
+
+	return delivery, true, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(delivery models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"status":           delivery.Status,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+		"delivered_at":     delivery.DeliveredAt,
+	}
+
+	_, err := r.deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": set})
+	return err
+}
+
+// getDeliveries retrieves the most recent deliveries matching the filter, newest first
+func (r *WebhookRepository) getDeliveries(filter bson.M, limit int64) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := r.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []models.WebhookDelivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// GetSubscriptionDeliveries retrieves the delivery log of a subscription, newest first
+func (r *WebhookRepository) GetSubscriptionDeliveries(id primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error) {
+	return r.getDeliveries(bson.M{"subscription_id": id}, limit)
+}
+
+// GetDeadDeliveries retrieves the deliveries that were given up on, newest first
+func (r *WebhookRepository) GetDeadDeliveries(limit int64) ([]models.WebhookDelivery, error) {
+	return r.getDeliveries(bson.M{"status": models.DeliveryDead}, limit)
+}
+
+// RequeueDelivery makes a dead delivery pending again with a fresh set of attempts
+func (r *WebhookRepository) RequeueDelivery(id primitive.ObjectID) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "status": models.DeliveryDead}
+	update := bson.M{
+		"$set": bson.M{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.WebhookDelivery{}, models.ErrorDeliveryNotFound
+		}
+		return models.WebhookDelivery{}, err
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,604 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+	"github.com/askarbtw/url-shortener-golang/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	backupManifestName = "manifest.json"
+	maxManifestBytes   = 1 << 20
+	maxRestoreErrors   = 100
+)
+
+// backupSection is one JSON Lines file of a backup archive, with how to read
+// its records from storage and how to write one back
+type backupSection struct {
+	name string
+	dump func(ctx context.Context, emit func(interface{}) error) error
+	load func(line []byte) error
+}
+
+// BackupService writes and restores backup archives of links, destination
+// revisions, click rollups and the short code sequence. An archive is a
+// gzip-compressed tar file holding a manifest and one JSON Lines file per
+// kind of record, so it does not depend on how the data is stored and can be
+// restored into any configured backend. Raw click events, which expire, are
+// not included; rollups keep their counts.
+type BackupService struct {
+	urls      *repositories.URLRepository
+	revisions *repositories.RevisionRepository
+	rollups   *repositories.RollupRepository
+	sequences SequenceStore
+	cache     *CacheService
+	apiKeys   []string // Fingerprints of the trusted API keys
+	maxBytes  int64
+}
+
+// NewBackupService creates a new instance of BackupService
+func NewBackupService(urls *repositories.URLRepository, revisions *repositories.RevisionRepository, rollups *repositories.RollupRepository, sequences SequenceStore, cache *CacheService, conf *config.Config) *BackupService {
+	var apiKeys []string
+	for _, key := range conf.TrustedAPIKeys {
+		if fingerprint := utils.HashAPIKey(key); fingerprint != "" {
+			apiKeys = append(apiKeys, fingerprint)
+		}
+	}
+
+	return &BackupService{
+		urls:      urls,
+		revisions: revisions,
+		rollups:   rollups,
+		sequences: sequences,
+		cache:     cache,
+		apiKeys:   apiKeys,
+		maxBytes:  int64(conf.RestoreMaxBytes),
+	}
+}
+
+// sections lists the files of an archive in the order they are written and restored
+func (s *BackupService) sections() []backupSection {
+	return []backupSection{
+		{name: "links.ndjson", dump: s.dumpLinks, load: s.loadLink},
+		{name: "revisions.ndjson", dump: s.dumpRevisions, load: s.loadRevision},
+		{name: "rollups.ndjson", dump: s.dumpRollups, load: s.loadRollup},
+		{name: "counters.ndjson", dump: s.dumpCounters, load: s.loadCounter},
+	}
+}
+
+// Backup is a snapshot whose files have been written to a temporary
+// directory, ready to be written out as an archive
+type Backup struct {
+	dir      string
+	manifest models.BackupManifest
+}
+
+// CreateBackup reads every section from storage into a temporary directory.
+// The snapshot is written out with Write and must be closed.
+func (s *BackupService) CreateBackup(ctx context.Context) (*Backup, error) {
+	dir, err := os.MkdirTemp("", "backup-*")
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{
+		dir: dir,
+		manifest: models.BackupManifest{
+			Format:    models.BackupFormat,
+			Version:   models.BackupVersion,
+			CreatedAt: time.Now().UTC(),
+			APIKeys:   s.apiKeys,
+		},
+	}
+	for _, section := range s.sections() {
+		file, err := spoolSection(ctx, dir, section)
+		if err != nil {
+			backup.Close()
+			return nil, fmt.Errorf("%s: %w", section.name, err)
+		}
+		backup.manifest.Files = append(backup.manifest.Files, file)
+	}
+
+	return backup, nil
+}
+
+// spoolSection writes the records of a section to a file, counting and hashing them
+func spoolSection(ctx context.Context, dir string, section backupSection) (models.BackupFile, error) {
+	file, err := os.Create(filepath.Join(dir, section.name))
+	if err != nil {
+		return models.BackupFile{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(file, hash)}
+	buffered := bufio.NewWriter(counter)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+
+	var records int64
+	err = section.dump(ctx, func(record interface{}) error {
+		records++
+		return encoder.Encode(record)
+	})
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		return models.BackupFile{}, err
+	}
+
+	return models.BackupFile{
+		Name:    section.name,
+		Records: records,
+		Bytes:   counter.n,
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Manifest describes the snapshot
+func (b *Backup) Manifest() models.BackupManifest {
+	return b.manifest
+}
+
+// Write writes the snapshot to w as a gzip-compressed tar archive, manifest first
+func (b *Backup) Write(w io.Writer) error {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+
+	manifest, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(archive, backupManifestName, bytes.NewReader(manifest), int64(len(manifest)), b.manifest.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, entry := range b.manifest.Files {
+		file, err := os.Open(filepath.Join(b.dir, entry.Name))
+		if err != nil {
+			return err
+		}
+		err = writeTarFile(archive, entry.Name, file, entry.Bytes, b.manifest.CreatedAt)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Close removes the snapshot's temporary files
+func (b *Backup) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+// writeTarFile adds a regular file to an archive
+func writeTarFile(archive *tar.Writer, name string, r io.Reader, size int64, modTime time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modTime,
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(archive, r)
+	return err
+}
+
+// RestoreUpload saves an uploaded archive to a temporary file and restores it
+func (s *BackupService) RestoreUpload(ctx context.Context, body io.Reader, apply bool) (models.RestoreReport, error) {
+	file, err := os.CreateTemp("", "restore-*.tar.gz")
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, io.LimitReader(body, s.maxBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > s.maxBytes {
+		err = models.ErrorBackupTooLarge
+	}
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	return s.Restore(ctx, file.Name(), apply)
+}
+
+// Restore verifies the checksums and record counts of an archive and, when
+// apply is set, loads it into storage. Nothing is written unless the whole
+// archive verifies. Records replace those with the same identity, so a
+// restore can be run again after an interruption; records that conflict with
+// different data already stored are reported as failures.
+func (s *BackupService) Restore(ctx context.Context, path string, apply bool) (models.RestoreReport, error) {
+	manifest, err := verifyBackup(path)
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	report := models.RestoreReport{
+		Version:        manifest.Version,
+		CreatedAt:      manifest.CreatedAt,
+		Applied:        apply,
+		MissingAPIKeys: s.missingAPIKeys(manifest.APIKeys),
+	}
+	files := make(map[string]*models.RestoreFileReport, len(manifest.Files))
+	for _, file := range manifest.Files {
+		report.Files = append(report.Files, models.RestoreFileReport{Name: file.Name, Records: file.Records})
+	}
+	for i := range report.Files {
+		files[report.Files[i].Name] = &report.Files[i]
+	}
+	if !apply {
+		return report, nil
+	}
+
+	loaders := make(map[string]func([]byte) error)
+	for _, section := range s.sections() {
+		loaders[section.name] = section.load
+	}
+
+	err = eachBackupEntry(path, func(name string, r io.Reader) error {
+		load, ok := loaders[name]
+		if !ok {
+			return nil
+		}
+		file := files[name]
+
+		reader := bufio.NewReader(r)
+		for record := int64(1); ; record++ {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) == 0 {
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if loadErr := load(line); loadErr != nil {
+				file.Failed++
+				if len(report.Errors) < maxRestoreErrors {
+					report.Errors = append(report.Errors, models.RestoreError{File: name, Record: record, Message: loadErr.Error()})
+				}
+			} else {
+				file.Restored++
+			}
+
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// missingAPIKeys returns the fingerprints trusted by a backup that are not configured here
+func (s *BackupService) missingAPIKeys(fingerprints []string) []string {
+	configured := make(map[string]bool, len(s.apiKeys))
+	for _, fingerprint := range s.apiKeys {
+		configured[fingerprint] = true
+	}
+
+	var missing []string
+	for _, fingerprint := range fingerprints {
+		if !configured[fingerprint] {
+			missing = append(missing, fingerprint)
+		}
+	}
+	return missing
+}
+
+// verifyBackup checks that an archive starts with a supported manifest and
+// holds exactly the files it lists, with their checksums and record counts
+func verifyBackup(path string) (models.BackupManifest, error) {
+	var manifest *models.BackupManifest
+	expected := make(map[string]models.BackupFile)
+	seen := make(map[string]bool)
+
+	err := eachBackupEntry(path, func(name string, r io.Reader) error {
+		if manifest == nil {
+			if name != backupManifestName {
+				return fmt.Errorf("%w: %s must come first", models.ErrorInvalidBackup, backupManifestName)
+			}
+			manifest = &models.BackupManifest{}
+			if err := json.NewDecoder(io.LimitReader(r, maxManifestBytes)).Decode(manifest); err != nil {
+				return fmt.Errorf("%w: %s: %v", models.ErrorInvalidBackup, backupManifestName, err)
+			}
+			if manifest.Format != models.BackupFormat {
+				return fmt.Errorf("%w: not a %s archive", models.ErrorInvalidBackup, models.BackupFormat)
+			}
+			if manifest.Version < 1 || manifest.Version > models.BackupVersion {
+				return fmt.Errorf("%w: unsupported version %d", models.ErrorInvalidBackup, manifest.Version)
+			}
+			for _, file := range manifest.Files {
+				expected[file.Name] = file
+			}
+			return nil
+		}
+
+		file, ok := expected[name]
+		if !ok || seen[name] {
+			return fmt.Errorf("%w: unexpected file %s", models.ErrorInvalidBackup, name)
+		}
+		seen[name] = true
+
+		hash := sha256.New()
+		lines := &lineCounter{}
+		if _, err := io.Copy(io.MultiWriter(hash, lines), r); err != nil {
+			return fmt.Errorf("%w: %s: %v", models.ErrorInvalidBackup, name, err)
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return fmt.Errorf("%w: checksum mismatch in %s", models.ErrorInvalidBackup, name)
+		}
+		if lines.n != file.Records {
+			return fmt.Errorf("%w: %s has %d records, expected %d", models.ErrorInvalidBackup, name, lines.n, file.Records)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.BackupManifest{}, err
+	}
+
+	if manifest == nil {
+		return models.BackupManifest{}, fmt.Errorf("%w: missing %s", models.ErrorInvalidBackup, backupManifestName)
+	}
+	for name := range expected {
+		if !seen[name] {
+			return models.BackupManifest{}, fmt.Errorf("%w: missing %s", models.ErrorInvalidBackup, name)
+		}
+	}
+
+	return *manifest, nil
+}
+
+// eachBackupEntry calls fn with every regular file of an archive, in order
+func eachBackupEntry(path string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("%w: %v", models.ErrorInvalidBackup, err)
+	}
+	archive := tar.NewReader(compressed)
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", models.ErrorInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := fn(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// dumpLinks emits every link, including those in the trash
+func (s *BackupService) dumpLinks(ctx context.Context, emit func(interface{}) error) error {
+	return s.urls.EachStoredURL(ctx, func(url models.URL) error {
+		record := models.BackupLink{URL: url}
+		if url.ImportedFrom != nil {
+			record.ImportedFrom = &models.BackupImportOrigin{
+				Job:    url.ImportedFrom.Job.Hex(),
+				Record: url.ImportedFrom.Record,
+			}
+		}
+		return emit(record)
+	})
+}
+
+// loadLink restores a link, replacing the link with the same ID
+func (s *BackupService) loadLink(line []byte) error {
+	var record models.BackupLink
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+	url := record.URL
+	if url.ID.IsZero() || url.ShortCode == "" || url.OriginalURL == "" {
+		return errors.New("link needs an id, a short code and a url")
+	}
+	if record.ImportedFrom != nil {
+		job, err := primitive.ObjectIDFromHex(record.ImportedFrom.Job)
+		if err != nil {
+			return fmt.Errorf("invalid import job id %q", record.ImportedFrom.Job)
+		}
+		url.ImportedFrom = &models.ImportOrigin{Job: job, Record: record.ImportedFrom.Record}
+	}
+
+	if err := s.urls.ReplaceStoredURL(url); err != nil {
+		return err
+	}
+	if s.cache != nil {
+		s.cache.InvalidateURL(url.ShortCode)
+	}
+	return nil
+}
+
+// dumpRevisions emits every destination revision
+func (s *BackupService) dumpRevisions(ctx context.Context, emit func(interface{}) error) error {
+	return s.revisions.EachRevision(ctx, func(revision models.Revision) error {
+		return emit(models.BackupRevision{
+			ID:        revision.ID.Hex(),
+			URLID:     revision.URLID.Hex(),
+			ShortCode: revision.ShortCode,
+			Number:    revision.Number,
+			OldURL:    revision.OldURL,
+			NewURL:    revision.NewURL,
+			Actor:     revision.Actor,
+			ActorNote: revision.ActorNote,
+			CreatedAt: revision.CreatedAt,
+		})
+	})
+}
+
+// loadRevision restores a destination revision, replacing the revision with the same ID
+func (s *BackupService) loadRevision(line []byte) error {
+	var record models.BackupRevision
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+	id, err := primitive.ObjectIDFromHex(record.ID)
+	if err != nil {
+		return fmt.Errorf("invalid revision id %q", record.ID)
+	}
+	urlID, err := primitive.ObjectIDFromHex(record.URLID)
+	if err != nil {
+		return fmt.Errorf("invalid link id %q", record.URLID)
+	}
+
+	return s.revisions.ReplaceRevision(models.Revision{
+		ID:        id,
+		URLID:     urlID,
+		ShortCode: record.ShortCode,
+		Number:    record.Number,
+		OldURL:    record.OldURL,
+		NewURL:    record.NewURL,
+		Actor:     record.Actor,
+		ActorNote: record.ActorNote,
+		CreatedAt: record.CreatedAt,
+	})
+}
+
+// dumpRollups emits every hourly and daily click rollup
+func (s *BackupService) dumpRollups(ctx context.Context, emit func(interface{}) error) error {
+	return s.rollups.EachRollup(ctx, func(rollup models.ClickRollup) error {
+		return emit(models.BackupRollup{
+			ShortCode:   rollup.ShortCode,
+			Granularity: rollup.Granularity,
+			Start:       rollup.Start.UTC(),
+			Clicks:      rollup.Clicks,
+			Bots:        rollup.Bots,
+			Visitors:    rollup.Visitors,
+			Referrers:   rollup.Referrers,
+			Countries:   rollup.Countries,
+			Platforms:   rollup.Platforms,
+		})
+	})
+}
+
+// loadRollup restores a click rollup, replacing the rollup of the same link, granularity and start
+func (s *BackupService) loadRollup(line []byte) error {
+	var record models.BackupRollup
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+	if record.ShortCode == "" || (record.Granularity != models.GranularityHour && record.Granularity != models.GranularityDay) {
+		return errors.New("rollup needs a short code and a granularity of hour or day")
+	}
+
+	return s.rollups.ReplaceRollup(models.ClickRollup{
+		ShortCode:   record.ShortCode,
+		Granularity: record.Granularity,
+		Start:       record.Start.UTC(),
+		Clicks:      record.Clicks,
+		Bots:        record.Bots,
+		Visitors:    record.Visitors,
+		Referrers:   record.Referrers,
+		Countries:   record.Countries,
+		Platforms:   record.Platforms,
+	})
+}
+
+// dumpCounters emits the position of the short code sequence
+func (s *BackupService) dumpCounters(ctx context.Context, emit func(interface{}) error) error {
+	// Leasing nothing reads the last value handed out
+	value, err := s.sequences.Lease(ShortCodeSequence, 0)
+	if err != nil {
+		return err
+	}
+	return emit(models.BackupCounter{Name: ShortCodeSequence, Value: value})
+}
+
+// loadCounter moves a sequence forward to the backup's position, so that
+// generated short codes do not collide with restored ones. A sequence is
+// never moved back.
+func (s *BackupService) loadCounter(line []byte) error {
+	var record models.BackupCounter
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+	if record.Name == "" {
+		return errors.New("counter needs a name")
+	}
+
+	current, err := s.sequences.Lease(record.Name, 0)
+	if err != nil {
+		return err
+	}
+	if record.Value > current {
+		_, err = s.sequences.Lease(record.Name, record.Value-current)
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// lineCounter counts the newline-terminated lines written to it
+type lineCounter struct {
+	n int64
+}
+
+func (l *lineCounter) Write(p []byte) (int, error) {
+	l.n += int64(bytes.Count(p, []byte{'\n'}))
+	return len(p), nil
+}
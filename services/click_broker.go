@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// clickChannel is the Redis pub/sub channel clicks are fanned out on
+const clickChannel = "clicks"
+
+// ClickSubscription receives the clicks of one link, or of every link
+type ClickSubscription struct {
+	Events      chan models.ClickEvent // Closed when the broker shuts down
+	shortCode   string                 // Empty for every link
+	includeBots bool
+	dropped     atomic.Int64
+}
+
+// Dropped returns how many clicks were dropped since the last call because
+// the subscriber fell behind
+func (s *ClickSubscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// ClickBroker fans clicks out to live subscribers. With Redis, clicks are
+// published on a pub/sub channel so subscribers on every replica see them;
+// without it, they only reach subscribers of this instance.
+type ClickBroker struct {
+	redis       *config.RedisCache
+	bufferSize  int
+	mu          sync.Mutex
+	subscribers map[*ClickSubscription]bool
+	closed      bool
+}
+
+// NewClickBroker creates a new instance of ClickBroker. Each subscriber
+// buffers up to bufferSize clicks; further clicks are dropped for it until it
+// catches up, so one slow client never holds back redirects or other clients.
+func NewClickBroker(redis *config.RedisCache, bufferSize int) *ClickBroker {
+	return &ClickBroker{
+		redis:       redis,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*ClickSubscription]bool),
+	}
+}
+
+// Start relays clicks from Redis to local subscribers until ctx is
+// cancelled, then closes every subscription
+func (b *ClickBroker) Start(ctx context.Context) {
+	go func() {
+		if b.redis != nil {
+			pubsub := b.redis.Client.Subscribe(ctx, clickChannel)
+			defer pubsub.Close()
+
+			messages := pubsub.Channel()
+		relay:
+			for {
+				select {
+				case <-ctx.Done():
+					break relay
+				case message, ok := <-messages:
+					if !ok {
+						break relay
+					}
+					var event models.ClickEvent
+					if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+						log.Printf("Error decoding click event: %v", err)
+						continue
+					}
+					b.deliver(event)
+				}
+			}
+		}
+
+		<-ctx.Done()
+		b.close()
+	}()
+}
+
+// Publish sends a click to the subscribers of its link and of every link
+func (b *ClickBroker) Publish(event models.ClickEvent) {
+	if b.redis != nil {
+		payload, err := json.Marshal(event)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			err = b.redis.Client.Publish(ctx, clickChannel, payload).Err()
+			cancel()
+			if err == nil {
+				return
+			}
+		}
+		// Still reach this instance's subscribers when Redis is unavailable
+		log.Printf("Error publishing click event: %v", err)
+	}
+
+	b.deliver(event)
+}
+
+// Subscribe starts receiving the clicks of a link, or of every link when
+// shortCode is empty, leaving out bots unless includeBots is set
+func (b *ClickBroker) Subscribe(shortCode string, includeBots bool) *ClickSubscription {
+	subscription := &ClickSubscription{
+		Events:      make(chan models.ClickEvent, b.bufferSize),
+		shortCode:   shortCode,
+		includeBots: includeBots,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(subscription.Events)
+		return subscription
+	}
+	b.subscribers[subscription] = true
+	return subscription
+}
+
+// Unsubscribe stops a subscription
+func (b *ClickBroker) Unsubscribe(subscription *ClickSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, subscription)
+}
+
+// deliver hands a click to local subscribers without ever blocking
+func (b *ClickBroker) deliver(event models.ClickEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscription := range b.subscribers {
+		if subscription.shortCode != "" && subscription.shortCode != event.ShortCode {
+			continue
+		}
+		if event.Bot && !subscription.includeBots {
+			continue
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
+
+// close ends every subscription so streams finish before the server shuts down
+func (b *ClickBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for subscription := range b.subscribers {
+		close(subscription.Events)
+		delete(b.subscribers, subscription)
+	}
+}
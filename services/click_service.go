@@ -0,0 +1,115 @@
+package services
+
+import (
+	"log"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// ClickService records the clicks served by redirects
+type ClickService struct {
+	urls    *URLService
+	events  *repositories.ClickRepository
+	rollups *repositories.RollupRepository
+	broker  *ClickBroker
+	uniques *UniqueVisitorCounter
+	bots    *utils.BotFilter
+	geoIP   *config.GeoIPDatabase
+}
+
+// NewClickService creates a new instance of ClickService
+func NewClickService(urls *URLService, events *repositories.ClickRepository, rollups *repositories.RollupRepository, broker *ClickBroker, uniques *UniqueVisitorCounter, bots *utils.BotFilter, geoIP *config.GeoIPDatabase) *ClickService {
+	return &ClickService{
+		urls:    urls,
+		events:  events,
+		rollups: rollups,
+		broker:  broker,
+		uniques: uniques,
+		bots:    bots,
+		geoIP:   geoIP,
+	}
+}
+
+// IsBot reports whether a redirect request was made by a bot rather than a person
+func (s *ClickService) IsBot(r *http.Request) bool {
+	return s.bots.IsBot(r)
+}
+
+// Record counts a click on a link, and on the variant served if any, counts
+// the visitor, stores the click for exports, adds it to the link's rollups and
+// streams it to live subscribers. Bot requests only count towards the link's
+// bot counts.
+func (s *ClickService) Record(shortCode string, variant string, visitor models.Visitor, referrer string) error {
+	// Rank 0 leaves the rollups' visitor sketches unchanged
+	register, rank := 0, uint8(0)
+	if visitor.Bot {
+		err := s.urls.IncrementBotCount(shortCode)
+		if err != nil {
+			return err
+		}
+		variant = ""
+	} else {
+		err := s.urls.IncrementAccessCount(shortCode, variant)
+		if err != nil {
+			return err
+		}
+		if hash, ok := s.uniques.Add(shortCode, visitor); ok {
+			register, rank = utils.HyperLogLogRegister(hash)
+		}
+	}
+
+	event := models.ClickEvent{
+		ShortCode: shortCode,
+		Variant:   variant,
+		Country:   s.geoIP.LookupCountry(visitor.IP),
+		Platform:  utils.DetectPlatform(visitor.UserAgent),
+		Referrer:  referrerHost(referrer),
+		Bot:       visitor.Bot,
+		Time:      visitor.Time.UTC().Truncate(time.Millisecond),
+	}
+	if err := s.events.InsertClick(event); err != nil {
+		log.Printf("Error storing click event: %v", err)
+	}
+	if err := s.rollups.AddClick(event, register, rank); err != nil {
+		log.Printf("Error updating click rollups: %v", err)
+	}
+
+	s.broker.Publish(event)
+	return nil
+}
+
+// UniqueVisitors returns the unique visitors of a link over the last days
+func (s *ClickService) UniqueVisitors(shortCode string, days int) (models.UniqueVisitorStats, error) {
+	return s.uniques.Stats(shortCode, days, time.Now())
+}
+
+// Subscribe starts receiving the clicks of a link, or of every link when
+// shortCode is empty, leaving out bots unless includeBots is set
+func (s *ClickService) Subscribe(shortCode string, includeBots bool) *ClickSubscription {
+	return s.broker.Subscribe(shortCode, includeBots)
+}
+
+// Unsubscribe stops a subscription
+func (s *ClickService) Unsubscribe(subscription *ClickSubscription) {
+	s.broker.Unsubscribe(subscription)
+}
+
+// referrerHost returns the host of a Referer header; full referrers can carry private paths
+func referrerHost(referrer string) string {
+	parsed, err := neturl.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// CompactEvents deletes the raw click events older than retention
+func (s *ClickService) CompactEvents(now time.Time, retention time.Duration) (int64, error) {
+	return s.events.DeleteClicksBefore(now.Add(-retention))
+}
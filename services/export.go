@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// Export formats
+const (
+	ExportCSV     = "csv"
+	ExportNDJSON  = "ndjson"
+	ExportParquet = "parquet"
+)
+
+// exportRowGroupSize is the number of rows a Parquet export buffers at a time
+const exportRowGroupSize = 10000
+
+// exportType is the type of the values in an export column
+type exportType int
+
+// Column types of an export
+const (
+	exportString    exportType = iota // string
+	exportInt64                       // int64
+	exportBool                        // bool
+	exportTimestamp                   // time.Time
+)
+
+// exportColumn describes one column of an export, whatever its format
+type exportColumn struct {
+	Name string
+	Type exportType
+}
+
+// parquetTypes maps export column types to Parquet column types
+var parquetTypes = map[exportType]utils.ParquetType{
+	exportString:    utils.ParquetString,
+	exportInt64:     utils.ParquetInt64,
+	exportBool:      utils.ParquetBool,
+	exportTimestamp: utils.ParquetTimestamp,
+}
+
+// linkExportColumns are the columns of a link export
+var linkExportColumns = []exportColumn{
+	{Name: "id", Type: exportString},
+	{Name: "short_code", Type: exportString},
+	{Name: "url", Type: exportString},
+	{Name: "title", Type: exportString},
+	{Name: "folder", Type: exportString},
+	{Name: "tags", Type: exportString}, // Comma-separated
+	{Name: "created_at", Type: exportTimestamp},
+	{Name: "updated_at", Type: exportTimestamp},
+	{Name: "access_count", Type: exportInt64},
+	{Name: "bot_count", Type: exportInt64},
+}
+
+// clickExportColumns are the columns of a click export
+var clickExportColumns = []exportColumn{
+	{Name: "time", Type: exportTimestamp},
+	{Name: "short_code", Type: exportString},
+	{Name: "variant", Type: exportString},
+	{Name: "country", Type: exportString},
+	{Name: "platform", Type: exportString},
+	{Name: "referrer", Type: exportString},
+	{Name: "bot", Type: exportBool},
+}
+
+// ExportService streams links and click events out for analysis elsewhere
+type ExportService struct {
+	urls   *repositories.URLRepository
+	clicks *repositories.ClickRepository
+}
+
+// NewExportService creates a new instance of ExportService
+func NewExportService(urls *repositories.URLRepository, clicks *repositories.ClickRepository) *ExportService {
+	return &ExportService{
+		urls:   urls,
+		clicks: clicks,
+	}
+}
+
+// ValidFormat reports whether a format can be exported
+func ValidFormat(format string) bool {
+	switch format {
+	case ExportCSV, ExportNDJSON, ExportParquet:
+		return true
+	default:
+		return false
+	}
+}
+
+// ExportLinks writes the links created in the filter's time range to w
+func (s *ExportService) ExportLinks(ctx context.Context, w io.Writer, format string, filter models.ExportFilter) error {
+	exporter, err := newExporter(w, format, linkExportColumns)
+	if err != nil {
+		return err
+	}
+
+	err = s.urls.EachURL(ctx, filter, func(url models.URL) error {
+		return exporter.Write([]interface{}{
+			url.ID.Hex(),
+			url.ShortCode,
+			url.OriginalURL,
+			url.Title,
+			url.Folder,
+			strings.Join(url.Tags, ","),
+			url.CreatedAt,
+			url.UpdatedAt,
+			int64(url.AccessCount),
+			int64(url.BotCount),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.Close()
+}
+
+// ExportClicks writes the click events in the filter's time range to w
+func (s *ExportService) ExportClicks(ctx context.Context, w io.Writer, format string, filter models.ExportFilter) error {
+	exporter, err := newExporter(w, format, clickExportColumns)
+	if err != nil {
+		return err
+	}
+
+	err = s.clicks.EachClick(ctx, filter, func(event models.ClickEvent) error {
+		return exporter.Write([]interface{}{
+			event.Time,
+			event.ShortCode,
+			event.Variant,
+			event.Country,
+			event.Platform,
+			event.Referrer,
+			event.Bot,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.Close()
+}
+
+// exporter writes rows in one export format
+type exporter interface {
+	Write(row []interface{}) error
+	Close() error
+}
+
+// newExporter creates the exporter for a format
+func newExporter(w io.Writer, format string, columns []exportColumn) (exporter, error) {
+	switch format {
+	case ExportCSV:
+		return newCSVExporter(w, columns)
+	case ExportNDJSON:
+		return &ndjsonExporter{w: bufio.NewWriter(w), columns: columns}, nil
+	case ExportParquet:
+		return utils.NewParquetWriter(w, parquetColumns(columns), exportRowGroupSize)
+	default:
+		return nil, models.ErrorInvalidExport
+	}
+}
+
+// parquetColumns describes export columns to the Parquet writer
+func parquetColumns(columns []exportColumn) []utils.ParquetColumn {
+	parquet := make([]utils.ParquetColumn, len(columns))
+	for i, column := range columns {
+		parquet[i] = utils.ParquetColumn{Name: column.Name, Type: parquetTypes[column.Type]}
+	}
+	return parquet
+}
+
+// csvExporter writes a header row followed by one line per row
+type csvExporter struct {
+	w *csv.Writer
+}
+
+// newCSVExporter creates a CSV exporter and writes the header row
+func newCSVExporter(w io.Writer, columns []exportColumn) (*csvExporter, error) {
+	exporter := &csvExporter{w: csv.NewWriter(w)}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := exporter.w.Write(header); err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
+
+// Write writes a row as one CSV line, with times in UTC RFC 3339
+func (e *csvExporter) Write(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, value := range row {
+		switch value := value.(type) {
+		case string:
+			record[i] = value
+		case int64:
+			record[i] = strconv.FormatInt(value, 10)
+		case bool:
+			record[i] = strconv.FormatBool(value)
+		case time.Time:
+			record[i] = value.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return e.w.Write(record)
+}
+
+// Close flushes the buffered lines
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonExporter writes one JSON object per line, keys in column order
+type ndjsonExporter struct {
+	w       *bufio.Writer
+	columns []exportColumn
+}
+
+// Write writes a row as one JSON object, with times in UTC
+func (e *ndjsonExporter) Write(row []interface{}) error {
+	e.w.WriteByte('{')
+	for i, value := range row {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC()
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		e.w.WriteString(strconv.Quote(e.columns[i].Name))
+		e.w.WriteByte(':')
+		e.w.Write(encoded)
+	}
+	e.w.WriteString("}\n")
+	return nil
+}
+
+// Close flushes the buffered lines
+func (e *ndjsonExporter) Close() error {
+	return e.w.Flush()
+}
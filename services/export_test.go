@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// testExportRows are click rows with values that need quoting or escaping
+func testExportRows() [][]interface{} {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123000000, time.FixedZone("CET", 3600))
+	return [][]interface{}{
+		{at, "launch", "", "DE", "desktop", "news.example.com", false},
+		{at.Add(time.Second), "a,b", "B", "", "mobile", "say \"hi\"\nthere", true},
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := newExporter(&buf, ExportCSV, clickExportColumns)
+	if err != nil {
+		t.Fatalf("newExporter: %v", err)
+	}
+	for _, row := range testExportRows() {
+		if err := exporter.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the export: %v", err)
+	}
+
+	want := [][]string{
+		{"time", "short_code", "variant", "country", "platform", "referrer", "bot"},
+		{"2024-03-01T11:30:00.123Z", "launch", "", "DE", "desktop", "news.example.com", "false"},
+		{"2024-03-01T11:30:01.123Z", "a,b", "B", "", "mobile", "say \"hi\"\nthere", "true"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("export has %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestNDJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := newExporter(&buf, ExportNDJSON, clickExportColumns)
+	if err != nil {
+		t.Fatalf("newExporter: %v", err)
+	}
+	for _, row := range testExportRows() {
+		if err := exporter.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("export has %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+
+	// Keys come in column order
+	want := `{"time":"2024-03-01T11:30:00.123Z","short_code":"launch","variant":"","country":"DE","platform":"desktop","referrer":"news.example.com","bot":false}`
+	if lines[0] != want {
+		t.Errorf("line 1 = %s, want %s", lines[0], want)
+	}
+
+	var click struct {
+		Time      time.Time `json:"time"`
+		ShortCode string    `json:"short_code"`
+		Referrer  string    `json:"referrer"`
+		Bot       bool      `json:"bot"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &click); err != nil {
+		t.Fatalf("line 2 is not JSON: %v", err)
+	}
+	row := testExportRows()[1]
+	if !click.Time.Equal(row[0].(time.Time)) || click.ShortCode != "a,b" || click.Referrer != "say \"hi\"\nthere" || !click.Bot {
+		t.Errorf("line 2 decoded to %+v, want the values of %v", click, row)
+	}
+}
+
+func TestNewExporterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newExporter(&bytes.Buffer{}, "xml", clickExportColumns); !errors.Is(err, models.ErrorInvalidExport) {
+		t.Errorf("newExporter(xml): err = %v, want %v", err, models.ErrorInvalidExport)
+	}
+}
+
+func TestParquetColumns(t *testing.T) {
+	columns := parquetColumns(clickExportColumns)
+	want := []utils.ParquetType{
+		utils.ParquetTimestamp,
+		utils.ParquetString,
+		utils.ParquetString,
+		utils.ParquetString,
+		utils.ParquetString,
+		utils.ParquetString,
+		utils.ParquetBool,
+	}
+	if len(columns) != len(want) {
+		t.Fatalf("got %d columns, want %d", len(columns), len(want))
+	}
+	for i, column := range columns {
+		if column.Name != clickExportColumns[i].Name || column.Type != want[i] {
+			t.Errorf("column %d = %+v, want %s of type %d", i, column, clickExportColumns[i].Name, want[i])
+		}
+	}
+}
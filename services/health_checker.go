@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+)
+
+const (
+	// healthCheckTick is how often the checker looks for links due a check
+	healthCheckTick      = time.Minute
+	maxHealthRedirects   = 10
+	healthWebhookTimeout = 10 * time.Second
+)
+
+// Health notification events
+const (
+	HealthEventBroken    = "link.broken"
+	HealthEventRecovered = "link.recovered"
+)
+
+// HealthChecker periodically checks that link destinations still respond
+type HealthChecker struct {
+	repository  *repositories.URLRepository
+	cache       *CacheService
+	client      *http.Client
+	interval    time.Duration // How often each link is rechecked
+	concurrency int
+	batchSize   int
+	hosts       *hostLimiter
+	webhookURL  string
+	webhook     *http.Client
+}
+
+// NewHealthChecker creates a new instance of HealthChecker
+func NewHealthChecker(repository *repositories.URLRepository, cache *CacheService, conf *config.Config) *HealthChecker {
+	return &HealthChecker{
+		repository:  repository,
+		cache:       cache,
+		client:      newOutboundClient(conf.HealthCheckTimeout, maxHealthRedirects, conf.HealthCheckAllowPrivate),
+		interval:    conf.HealthCheckInterval,
+		concurrency: conf.HealthCheckConcurrency,
+		batchSize:   conf.HealthCheckBatchSize,
+		hosts:       newHostLimiter(conf.HealthCheckHostDelay),
+		webhookURL:  conf.HealthWebhookURL,
+		webhook:     &http.Client{Timeout: healthWebhookTimeout},
+	}
+}
+
+// Start runs the checker in the background until ctx is cancelled
+func (c *HealthChecker) Start(ctx context.Context) {
+	go runPeriodically(ctx, healthCheckTick, func() {
+		checked, err := c.CheckDue(ctx)
+		if err != nil {
+			log.Printf("Error checking link health: %v", err)
+			return
+		}
+		if checked > 0 {
+			log.Printf("Checked the health of %d URLs", checked)
+		}
+	})
+}
+
+// CheckDue checks a batch of the links due a check and returns how many were checked.
+// At most concurrency checks run at once, and requests to the same host are
+// spaced out by the host delay.
+func (c *HealthChecker) CheckDue(ctx context.Context) (int, error) {
+	urls, err := c.repository.GetURLsDueForHealthCheck(time.Now().Add(-c.interval), int64(c.batchSize))
+	if err != nil {
+		return 0, err
+	}
+
+	slots := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url models.URL) {
+			defer wg.Done()
+
+			// Wait for the host before taking a slot, so slots are not held idle
+			if err := c.hosts.wait(ctx, hostOf(url.OriginalURL)); err != nil {
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			c.check(ctx, url)
+		}(url)
+	}
+	wg.Wait()
+	c.hosts.prune(time.Now())
+
+	return len(urls), nil
+}
+
+// check checks one link, stores the result and sends a notification when
+// the link broke or recovered
+func (c *HealthChecker) check(ctx context.Context, url models.URL) {
+	health := c.probe(ctx, url.OriginalURL)
+
+	// Keep when the link broke, and treat rate limiting as inconclusive
+	var previous *models.LinkHealth
+	if url.Health != nil && url.Health.CheckedURL == url.OriginalURL {
+		previous = url.Health
+	}
+	if health.StatusCode == http.StatusTooManyRequests && previous != nil {
+		health.Broken = previous.Broken
+	}
+	if health.Broken {
+		health.BrokenSince = &health.CheckedAt
+		if previous != nil && previous.BrokenSince != nil {
+			health.BrokenSince = previous.BrokenSince
+		}
+	}
+
+	// Update in database; skipped if the destination changed while checking
+	updatedURL, err := c.repository.SetHealth(url.ShortCode, health)
+	if err != nil {
+		if err != models.ErrorURLNotFound {
+			log.Printf("Error storing health of %s: %v", url.ShortCode, err)
+		}
+		return
+	}
+
+	// Update cache
+	if c.cache != nil {
+		c.cache.SetURL(updatedURL)
+	}
+
+	wasBroken := previous != nil && previous.Broken
+	switch {
+	case health.Broken && !wasBroken:
+		c.notify(ctx, HealthEventBroken, updatedURL, health)
+	case !health.Broken && wasBroken:
+		c.notify(ctx, HealthEventRecovered, updatedURL, health)
+	}
+}
+
+// probe requests a destination with HEAD, falling back to GET for servers
+// that reject or mishandle HEAD. Error responses other than 429 Too Many
+// Requests and failed requests count as broken.
+func (c *HealthChecker) probe(ctx context.Context, destination string) models.LinkHealth {
+	health := models.LinkHealth{CheckedURL: destination}
+
+	start := time.Now()
+	resp, err := c.request(ctx, http.MethodHead, destination)
+	if err != nil || resp.StatusCode >= 400 {
+		start = time.Now()
+		resp, err = c.request(ctx, http.MethodGet, destination)
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+	health.CheckedAt = time.Now()
+
+	if err != nil {
+		health.Error = err.Error()
+		health.Broken = true
+		return health
+	}
+
+	health.StatusCode = resp.StatusCode
+	health.FinalURL = resp.Request.URL.String()
+	health.Broken = resp.StatusCode >= 400 && resp.StatusCode != http.StatusTooManyRequests
+	return health
+}
+
+// request sends a request without reading the response body
+func (c *HealthChecker) request(ctx context.Context, method, destination string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "url-shortener-health/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// notify posts a health notification to the webhook, if one is configured
+func (c *HealthChecker) notify(ctx context.Context, event string, url models.URL, health models.LinkHealth) {
+	if c.webhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(models.HealthNotification{
+		Event:     event,
+		ShortCode: url.ShortCode,
+		URL:       url.OriginalURL,
+		Health:    health,
+	})
+	if err != nil {
+		log.Printf("Error encoding health notification: %v", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating health notification: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.webhook.Do(req)
+	if err != nil {
+		log.Printf("Error sending %s notification for %s: %v", event, url.ShortCode, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Health webhook rejected %s notification for %s: %s", event, url.ShortCode, resp.Status)
+	}
+}
+
+// hostOf returns the host name of a URL, or the URL itself when it cannot be parsed
+func hostOf(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return rawURL
+	}
+	return parsed.Hostname()
+}
+
+// hostLimiter spaces out requests to the same host
+type hostLimiter struct {
+	mu    sync.Mutex
+	delay time.Duration
+	next  map[string]time.Time // When each host may next be requested
+}
+
+// newHostLimiter creates a hostLimiter allowing one request per host every delay
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		delay: delay,
+		next:  make(map[string]time.Time),
+	}
+}
+
+// wait reserves the next request slot for host and sleeps until it arrives
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.delay)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for %s: %w", host, ctx.Err())
+	}
+}
+
+// prune forgets hosts whose next slot has passed
+func (l *hostLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for host, slot := range l.next {
+		if slot.Before(now) {
+			delete(l.next, host)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SequenceStore hands out disjoint ranges of a shared sequence
+type SequenceStore interface {
+	// Lease reserves the next size values and returns the last value of the range
+	Lease(name string, size uint64) (uint64, error)
+}
+
+// IDAllocator hands out collision-free IDs across replicas. Each instance
+// leases a block of IDs from the shared store and serves them locally, so
+// the store is only contacted once per block. When a state file is
+// configured, the unused part of the block survives restarts; the state is
+// written before an ID is returned, so a crash can skip IDs but never reuse them.
+type IDAllocator struct {
+	store     SequenceStore
+	name      string
+	blockSize uint64
+	stateFile string
+
+	mu    sync.Mutex
+	state leaseState
+}
+
+// leaseState is the current block of an allocator; next > end means it is used up
+type leaseState struct {
+	Next uint64 `json:"next"`
+	End  uint64 `json:"end"`
+}
+
+// NewIDAllocator creates a new instance of IDAllocator
+func NewIDAllocator(store SequenceStore, name string, blockSize uint64, stateFile string) *IDAllocator {
+	if blockSize == 0 {
+		blockSize = 1
+	}
+
+	a := &IDAllocator{
+		store:     store,
+		name:      name,
+		blockSize: blockSize,
+		stateFile: stateFile,
+		state:     leaseState{Next: 1, End: 0},
+	}
+
+	if stateFile != "" {
+		if err := a.loadState(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: Failed to load ID lease state from %s: %v", stateFile, err)
+		}
+	}
+
+	return a
+}
+
+// Next returns the next ID, leasing a new block when the current one is used up
+func (a *IDAllocator) Next() (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.state.Next > a.state.End {
+		end, err := a.store.Lease(a.name, a.blockSize)
+		if err != nil {
+			return 0, err
+		}
+		a.state = leaseState{Next: end - a.blockSize + 1, End: end}
+	}
+
+	id := a.state.Next
+	a.state.Next++
+
+	if err := a.saveState(); err != nil {
+		// Without persisted state a restart could hand this ID out again
+		a.state.Next = a.state.End + 1
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// loadState restores the current block from the state file
+func (a *IDAllocator) loadState() error {
+	data, err := os.ReadFile(a.stateFile)
+	if err != nil {
+		return err
+	}
+
+	var state leaseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	a.state = state
+	return nil
+}
+
+// saveState atomically writes the current block to the state file
+func (a *IDAllocator) saveState() error {
+	if a.stateFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(a.state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.stateFile), ".id-lease-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), a.stateFile)
+}
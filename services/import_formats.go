@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// importFieldAliases maps the field names used by other shorteners and by
+// spreadsheets to the fields of an import record, in order of preference.
+// Names are compared lower-cased without spaces, '-' and '_'.
+var importFieldAliases = map[string][]string{
+	"shortCode": {"shortcode", "code", "alias", "slug", "keyword", "backhalf", "address", "shorturl", "shortlink", "link"},
+	"url":       {"url", "longurl", "originalurl", "destination", "destinationurl", "target", "targeturl", "longlink"},
+	"title":     {"title", "name"},
+	"notes":     {"notes", "note", "description", "comment"},
+	"tags":      {"tags", "tag", "labels"},
+	"folder":    {"folder", "group"},
+	"createdAt": {"createdat", "created", "datecreated", "creationdate", "createdtime", "timestamp", "date"},
+	"clicks":    {"clicks", "accesscount", "visits", "visitscount", "visitcount", "visitssummary", "hits", "totalclicks", "clickcount"},
+}
+
+// importFieldNormaliser strips the separators ignored when matching field names
+var importFieldNormaliser = strings.NewReplacer(" ", "", "-", "", "_", "")
+
+// importRecordKeys are the keys under which JSON exports nest their records
+var importRecordKeys = []string{"links", "shortUrls", "data", "urls", "items", "results"}
+
+// importTimeLayouts are the date formats accepted for creation dates
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+}
+
+// readImportRecords calls fn with every record of an import file in file
+// order, numbering them from 1. Records that cannot be read are passed with
+// an error so they can be reported without stopping the import; fn stops the
+// import by returning an error. The order is stable across calls, which is
+// what lets an interrupted import skip the records it already processed.
+func readImportRecords(r io.Reader, format string, fn func(number int, record models.ImportRecord, err error) error) error {
+	switch format {
+	case models.ImportCSV:
+		return readCSVRecords(r, fn)
+	case models.ImportNDJSON:
+		return readNDJSONRecords(r, fn)
+	case models.ImportJSON:
+		return readJSONRecords(r, fn)
+	default:
+		return models.ErrorInvalidImport
+	}
+}
+
+// readCSVRecords reads a CSV file whose first row names the columns
+func readCSVRecords(r io.Reader, fn func(int, models.ImportRecord, error) error) error {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.TrimPrefix(name, "\ufeff") // Spreadsheet byte order marks
+	}
+
+	for number := 1; ; number++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// A malformed line is reported and the next one is read
+			if _, ok := err.(*csv.ParseError); ok {
+				if err := fn(number, models.ImportRecord{}, err); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		fields := make(map[string]interface{}, len(row))
+		for i, value := range row {
+			if i < len(columns) && value != "" {
+				fields[columns[i]] = value
+			}
+		}
+		record, recordErr := parseImportRecord(fields)
+		if err := fn(number, record, recordErr); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSONRecords reads one JSON object per line
+func readNDJSONRecords(r io.Reader, fn func(int, models.ImportRecord, error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	number := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		number++
+
+		var fields map[string]interface{}
+		var record models.ImportRecord
+		err := json.Unmarshal(line, &fields)
+		if err == nil {
+			record, err = parseImportRecord(fields)
+		}
+		if err := fn(number, record, err); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readJSONRecords reads a JSON export: either an array of records, which is
+// streamed, or an object holding the records under a key such as "links",
+// "data" or "shortUrls", as an array or as an object keyed by short code
+func readJSONRecords(r io.Reader, fn func(int, models.ImportRecord, error) error) error {
+	reader := bufio.NewReader(r)
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		for number := 1; decoder.More(); number++ {
+			var fields map[string]interface{}
+			var record models.ImportRecord
+			err := decoder.Decode(&fields)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", number, err)
+			}
+			record, err = parseImportRecord(fields)
+			if err := fn(number, record, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return err
+	}
+	records, ok := findImportRecords(root)
+	if !ok {
+		return fmt.Errorf("no records found; expected an array or an object with %s", strings.Join(importRecordKeys, ", "))
+	}
+
+	for i, value := range records {
+		fields, ok := value.(map[string]interface{})
+		var record models.ImportRecord
+		err := fmt.Errorf("record is not an object")
+		if ok {
+			record, err = parseImportRecord(fields)
+		}
+		if err := fn(i+1, record, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstNonSpace returns the first non-whitespace byte of r without consuming it
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n', 0xef, 0xbb, 0xbf: // Also skip a UTF-8 byte order mark
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+// findImportRecords locates the records of a decoded JSON export
+func findImportRecords(value interface{}) ([]interface{}, bool) {
+	switch value := value.(type) {
+	case []interface{}:
+		return value, true
+	case map[string]interface{}:
+		for _, key := range importRecordKeys {
+			if nested, ok := value[key]; ok {
+				if records, ok := findImportRecords(nested); ok {
+					return records, true
+				}
+			}
+		}
+
+		// An object of records keyed by short code, in a stable order
+		keys := make([]string, 0, len(value))
+		for key, nested := range value {
+			if _, ok := nested.(map[string]interface{}); !ok {
+				return nil, false
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			return nil, false
+		}
+		sort.Strings(keys)
+		records := make([]interface{}, len(keys))
+		for i, key := range keys {
+			records[i] = value[key]
+		}
+		return records, true
+	default:
+		return nil, false
+	}
+}
+
+// parseImportRecord maps the fields of one exported link to an import record
+func parseImportRecord(fields map[string]interface{}) (models.ImportRecord, error) {
+	normalised := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		key := importFieldNormaliser.Replace(strings.ToLower(strings.TrimSpace(name)))
+		normalised[key] = value
+	}
+	lookup := func(field string) (interface{}, bool) {
+		for _, alias := range importFieldAliases[field] {
+			if value, ok := normalised[alias]; ok && value != nil && value != "" {
+				return value, true
+			}
+		}
+		return nil, false
+	}
+
+	var record models.ImportRecord
+	if value, ok := lookup("url"); ok {
+		record.URL = strings.TrimSpace(fmt.Sprint(value))
+	}
+	if record.URL == "" {
+		return record, fmt.Errorf("missing destination URL")
+	}
+
+	if value, ok := lookup("shortCode"); ok {
+		record.ShortCode = shortCodeFromLink(fmt.Sprint(value))
+	}
+	if value, ok := lookup("title"); ok {
+		record.Title = strings.TrimSpace(fmt.Sprint(value))
+	}
+	if value, ok := lookup("notes"); ok {
+		record.Notes = strings.TrimSpace(fmt.Sprint(value))
+	}
+	if value, ok := lookup("folder"); ok {
+		record.Folder = strings.TrimSpace(fmt.Sprint(value))
+	}
+
+	if value, ok := lookup("tags"); ok {
+		switch value := value.(type) {
+		case []interface{}:
+			for _, tag := range value {
+				record.Tags = append(record.Tags, fmt.Sprint(tag))
+			}
+		default:
+			record.Tags = strings.FieldsFunc(fmt.Sprint(value), func(r rune) bool {
+				return r == ',' || r == ';' || r == '|'
+			})
+		}
+	}
+
+	if value, ok := lookup("createdAt"); ok {
+		createdAt, err := parseImportTime(value)
+		if err != nil {
+			return record, err
+		}
+		record.CreatedAt = &createdAt
+	}
+
+	if value, ok := lookup("clicks"); ok {
+		// Shlink nests the count as visitsSummary.total
+		if summary, ok := value.(map[string]interface{}); ok {
+			value = summary["total"]
+		}
+		clicks, err := parseImportCount(value)
+		if err != nil {
+			return record, err
+		}
+		record.Clicks = &clicks
+	}
+
+	return record, nil
+}
+
+// shortCodeFromLink returns the short code of a short link such as
+// "https://bit.ly/abc123" or "bit.ly/abc123", or the value itself when it is
+// just a code
+func shortCodeFromLink(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.IndexAny(value, "?#"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimRight(value, "/")
+	if strings.Contains(value, "/") {
+		value = path.Base(value)
+	}
+	return value
+}
+
+// parseImportTime reads a creation date as a string in a common layout or as Unix seconds
+func parseImportTime(value interface{}) (time.Time, error) {
+	text := strings.TrimSpace(fmt.Sprint(value))
+	if seconds, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised creation date %q", text)
+}
+
+// parseImportCount reads a click count given as a number or a numeric string
+func parseImportCount(value interface{}) (int, error) {
+	text := strings.ReplaceAll(strings.TrimSpace(fmt.Sprint(value)), ",", "")
+	count, err := strconv.ParseFloat(text, 64)
+	if err != nil || count < 0 || count > math.MaxInt32 || count != math.Trunc(count) {
+		return 0, fmt.Errorf("invalid click count %q", text)
+	}
+	return int(count), nil
+}
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+	"github.com/askarbtw/url-shortener-golang/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	importPollInterval = 5 * time.Second
+	importLease        = 2 * time.Minute // Renewed on every progress save
+	importSaveInterval = 2 * time.Second // Progress is saved at least this often
+	importSaveEvery    = 500             // and every this many records
+	maxImportErrors    = 100
+	maxImportRenames   = 1000
+	maxRenameSuffix    = 9 // Renames try code-2 to code-9 before generating a code
+	importJobListLimit = 50
+)
+
+// importActor is recorded as the author of the revisions imports create
+var importActor = models.Actor{Name: "import"}
+
+// Import record outcomes
+const (
+	importCreated     = "created"
+	importOverwritten = "overwritten"
+	importRenamed     = "renamed"
+	importSkipped     = "skipped"
+)
+
+// errImportCancelled stops a job that was cancelled while it ran
+var errImportCancelled = errors.New("import cancelled")
+
+// ImportService imports links exported from other shorteners or spreadsheets.
+// Each import is a job that a background worker runs record by record, saving
+// its progress so that it continues where it stopped after a restart.
+// Imported links keep their short code, creation date and click count when
+// the file has them; imports do not notify webhooks or fetch page metadata.
+type ImportService struct {
+	urls     *URLService
+	jobs     *repositories.ImportRepository
+	dir      string
+	maxBytes int64
+	wake     chan struct{}
+}
+
+// NewImportService creates a new instance of ImportService
+func NewImportService(urls *URLService, jobs *repositories.ImportRepository, conf *config.Config) *ImportService {
+	return &ImportService{
+		urls:     urls,
+		jobs:     jobs,
+		dir:      conf.ImportDir,
+		maxBytes: int64(conf.ImportMaxBytes),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// CreateJob stores an uploaded import file and queues it for the worker
+func (s *ImportService) CreateJob(body io.Reader, source string, format string, conflict string, dryRun bool) (models.ImportJob, error) {
+	if !validImport(format, conflict) {
+		return models.ImportJob{}, models.ErrorInvalidImport
+	}
+
+	// Keep a copy of the upload so the job can resume after a restart
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return models.ImportJob{}, err
+	}
+	file, err := os.CreateTemp(s.dir, "import-*."+format)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	written, err := io.Copy(file, io.LimitReader(body, s.maxBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > s.maxBytes {
+		err = models.ErrorImportTooLarge
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return models.ImportJob{}, err
+	}
+
+	job, err := s.jobs.CreateJob(newImportJob(source, format, conflict, dryRun, file.Name(), true, false))
+	if err != nil {
+		os.Remove(file.Name())
+		return models.ImportJob{}, err
+	}
+
+	s.wakeWorker()
+	return withProgress(job), nil
+}
+
+// CreateLocalJob creates a job for a local file, to be run by the import command with RunJob
+func (s *ImportService) CreateLocalJob(path string, format string, conflict string, dryRun bool) (models.ImportJob, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if !validImport(format, conflict) {
+		return models.ImportJob{}, models.ErrorInvalidImport
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return models.ImportJob{}, err
+	}
+
+	job, err := s.jobs.CreateJob(newImportJob(filepath.Base(path), format, conflict, dryRun, path, false, true))
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	return withProgress(job), nil
+}
+
+// GetJob retrieves an import job with its progress
+func (s *ImportService) GetJob(id string) (models.ImportJob, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ImportJob{}, models.ErrorImportNotFound
+	}
+
+	job, err := s.jobs.GetJob(objectID)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	return withProgress(job), nil
+}
+
+// GetJobs retrieves the latest import jobs, without their errors and renames
+func (s *ImportService) GetJobs() ([]models.ImportJob, error) {
+	jobs, err := s.jobs.GetJobs(importJobListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		jobs[i] = withProgress(jobs[i])
+	}
+	return jobs, nil
+}
+
+// CancelJob stops a job; a running job stops at its next progress save
+func (s *ImportService) CancelJob(id string) (models.ImportJob, error) {
+	return s.setStatus(id, []string{models.ImportPending, models.ImportRunning, models.ImportInterrupted, models.ImportFailed}, models.ImportCancelled)
+}
+
+// ResumeJob queues a failed, cancelled or interrupted job to continue after
+// the last record it processed
+func (s *ImportService) ResumeJob(id string) (models.ImportJob, error) {
+	job, err := s.setStatus(id, []string{models.ImportFailed, models.ImportCancelled, models.ImportInterrupted}, models.ImportPending)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	s.wakeWorker()
+	return job, nil
+}
+
+// setStatus moves a job between statuses
+func (s *ImportService) setStatus(id string, from []string, to string) (models.ImportJob, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ImportJob{}, models.ErrorImportNotFound
+	}
+
+	job, err := s.jobs.SetStatus(objectID, from, to)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	return withProgress(job), nil
+}
+
+// Start runs queued server jobs in the background until ctx is cancelled, one at a time
+func (s *ImportService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(importPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.runQueued(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// wakeWorker tells an idle worker there is a job waiting
+func (s *ImportService) wakeWorker() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runQueued runs jobs until none are waiting
+func (s *ImportService) runQueued(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok, err := s.jobs.ClaimNextJob(time.Now(), importLease)
+		if err != nil {
+			log.Printf("Error claiming import job: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		log.Printf("Running import %s from %s", job.ID.Hex(), job.Source)
+		s.run(ctx, job, nil)
+	}
+}
+
+// RunJob runs a job in the foreground, calling progress as it goes, and
+// returns the job as it ended. Cancelling ctx interrupts the job.
+func (s *ImportService) RunJob(ctx context.Context, id string, progress func(models.ImportJob)) (models.ImportJob, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ImportJob{}, models.ErrorImportNotFound
+	}
+
+	job, ok, err := s.jobs.ClaimJob(objectID, time.Now(), importLease)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	if !ok {
+		// Missing, finished, or run by someone else
+		if _, err := s.jobs.GetJob(objectID); err != nil {
+			return models.ImportJob{}, err
+		}
+		return models.ImportJob{}, models.ErrorImportState
+	}
+
+	s.run(ctx, job, progress)
+	return s.GetJob(id)
+}
+
+// run imports the records of a claimed job that were not processed yet
+func (s *ImportService) run(ctx context.Context, job models.ImportJob, progress func(models.ImportJob)) {
+	file, err := os.Open(job.FilePath)
+	if err != nil {
+		s.finish(job, models.ImportFailed, err)
+		return
+	}
+	defer file.Close()
+
+	if job.StartedAt == nil {
+		now := time.Now()
+		job.StartedAt = &now
+	}
+
+	// Count the records once so progress can be reported
+	if job.Total == 0 {
+		err := readImportRecords(file, job.Format, func(int, models.ImportRecord, error) error {
+			job.Total++
+			return nil
+		})
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			s.finish(job, models.ImportFailed, err)
+			return
+		}
+	}
+
+	save := func() error {
+		running, err := s.jobs.SaveProgress(job, time.Now().Add(importLease))
+		if err != nil {
+			return err
+		}
+		if !running {
+			return errImportCancelled
+		}
+		if progress != nil {
+			progress(withProgress(job))
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool) // Short codes seen by a dry run
+	lastSave := time.Now()
+	err = readImportRecords(file, job.Format, func(number int, record models.ImportRecord, recordErr error) error {
+		if number <= job.Processed {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.importRecord(&job, number, record, recordErr, seen)
+		job.Processed = number
+
+		if number%importSaveEvery == 0 || time.Since(lastSave) >= importSaveInterval {
+			lastSave = time.Now()
+			return save()
+		}
+		return nil
+	})
+	if err == nil || ctx.Err() != nil {
+		if saveErr := save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+
+	switch {
+	case errors.Is(err, errImportCancelled):
+		log.Printf("Import %s cancelled after %d records", job.ID.Hex(), job.Processed)
+	case ctx.Err() != nil:
+		s.finish(job, models.ImportInterrupted, nil)
+	case err != nil:
+		s.finish(job, models.ImportFailed, err)
+	default:
+		s.finish(job, models.ImportCompleted, nil)
+		if job.OwnsFile {
+			os.Remove(job.FilePath)
+		}
+	}
+}
+
+// finish records how a job ended
+func (s *ImportService) finish(job models.ImportJob, status string, jobErr error) {
+	message := ""
+	if jobErr != nil {
+		message = jobErr.Error()
+		log.Printf("Import %s failed: %v", job.ID.Hex(), jobErr)
+	}
+
+	if err := s.jobs.FinishJob(job.ID, status, message); err != nil {
+		log.Printf("Error finishing import %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// importRecord imports one record and counts the outcome
+func (s *ImportService) importRecord(job *models.ImportJob, number int, record models.ImportRecord, recordErr error, seen map[string]bool) {
+	outcome, newCode := "", ""
+	err := recordErr
+	if err == nil {
+		origin := models.ImportOrigin{Job: job.ID, Record: number}
+		outcome, newCode, err = s.apply(record, origin, job.Conflict, job.DryRun, seen)
+	}
+	if err != nil {
+		job.Failed++
+		if len(job.Errors) < maxImportErrors {
+			job.Errors = append(job.Errors, models.ImportError{Record: number, ShortCode: record.ShortCode, Message: err.Error()})
+		}
+		return
+	}
+
+	switch outcome {
+	case importCreated:
+		job.Created++
+	case importOverwritten:
+		job.Overwritten++
+	case importSkipped:
+		job.Skipped++
+	case importRenamed:
+		job.Renamed++
+		if len(job.Renames) < maxImportRenames && newCode != "" {
+			job.Renames = append(job.Renames, models.ImportRename{From: record.ShortCode, To: newCode})
+		}
+	}
+}
+
+// apply imports one record under the job's conflict policy. It returns the
+// outcome and, for renamed links, the new short code.
+func (s *ImportService) apply(record models.ImportRecord, origin models.ImportOrigin, conflict string, dryRun bool, seen map[string]bool) (string, string, error) {
+	url, err := importedURL(record)
+	if err != nil {
+		return "", "", err
+	}
+	url.ImportedFrom = &origin
+	repository := s.urls.repository
+
+	// Links without a short code get a generated one
+	if record.ShortCode == "" {
+		if dryRun {
+			return importCreated, "", nil
+		}
+		if _, err := s.createWithGeneratedCode(url); err != nil {
+			return "", "", err
+		}
+		return importCreated, "", nil
+	}
+
+	code := record.ShortCode
+	if !utils.ValidateAlias(code) || reservedAliases[strings.ToLower(code)] {
+		return "", "", models.ErrorInvalidAlias
+	}
+	if !s.urls.filter.Allowed(code) {
+		return "", "", models.ErrorBlockedAlias
+	}
+	url.ShortCode = code
+
+	if dryRun {
+		return s.dryRun(code, conflict, seen)
+	}
+
+	created, err := repository.InsertImportedURL(url)
+	if err == nil {
+		s.urls.recordRevision(created, "", importActor)
+		return importCreated, "", nil
+	}
+	if err != models.ErrorShortCodeExists {
+		return "", "", err
+	}
+
+	switch conflict {
+	case models.ConflictOverwrite:
+		updated, previousURL, err := repository.OverwriteImportedURL(url, record.CreatedAt != nil, record.Clicks != nil)
+		if err == models.ErrorURLNotFound {
+			return "", "", fmt.Errorf("short code belongs to a deleted link")
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if updated.OriginalURL != previousURL {
+			s.urls.recordRevision(updated, previousURL, importActor)
+		}
+		if s.urls.cache != nil {
+			s.urls.cache.InvalidateURL(code)
+		}
+		return importOverwritten, "", nil
+
+	case models.ConflictRename:
+		return s.rename(url)
+
+	default:
+		return importSkipped, "", nil
+	}
+}
+
+// rename imports a link whose short code is taken under code-2 to code-9, or
+// a generated code when those are taken too. A link already imported under
+// one of those codes, such as by an earlier run of the same import, is skipped.
+func (s *ImportService) rename(url models.URL) (string, string, error) {
+	repository := s.urls.repository
+	code := url.ShortCode
+
+	candidates := []string{code}
+	for n := 2; n <= maxRenameSuffix; n++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%d", code, n))
+	}
+
+	for i, candidate := range candidates {
+		existing, err := repository.GetURLByShortCode(candidate)
+		if err == nil && existing.OriginalURL == url.OriginalURL {
+			return importSkipped, "", nil
+		}
+		if i == 0 || !utils.ValidateAlias(candidate) || !s.urls.filter.Allowed(candidate) {
+			continue
+		}
+
+		url.ShortCode = candidate
+		created, err := repository.InsertImportedURL(url)
+		if err == nil {
+			s.urls.recordRevision(created, "", importActor)
+			return importRenamed, candidate, nil
+		}
+		if err != models.ErrorShortCodeExists {
+			return "", "", err
+		}
+	}
+
+	created, err := s.createWithGeneratedCode(url)
+	if err != nil {
+		return "", "", err
+	}
+	return importRenamed, created.ShortCode, nil
+}
+
+// createWithGeneratedCode imports a link under a generated short code. Unlike
+// links imported under their own code, a replayed record cannot be recognised
+// by its code, so the link created by an earlier run of the same job for the
+// same record is looked up by its origin and returned instead.
+func (s *ImportService) createWithGeneratedCode(url models.URL) (models.URL, error) {
+	repository := s.urls.repository
+
+	existing, err := repository.GetImportedURL(*url.ImportedFrom)
+	if err == nil {
+		return existing, nil
+	}
+	if err != models.ErrorURLNotFound {
+		return models.URL{}, err
+	}
+
+	created, err := s.urls.createWithGeneratedCode(url, repository.InsertImportedURL)
+	if err != nil {
+		return models.URL{}, err
+	}
+	s.urls.recordRevision(created, "", importActor)
+	return created, nil
+}
+
+// dryRun reports what importing a record under a short code would do
+func (s *ImportService) dryRun(code string, conflict string, seen map[string]bool) (string, string, error) {
+	repository := s.urls.repository
+
+	seenInFile := seen[code]
+	seen[code] = true
+	if !seenInFile {
+		taken, err := repository.ShortCodeTaken(code)
+		if err != nil {
+			return "", "", err
+		}
+		if !taken {
+			return importCreated, "", nil
+		}
+	}
+
+	switch conflict {
+	case models.ConflictOverwrite:
+		if !seenInFile {
+			if _, err := repository.GetURLByShortCode(code); err == models.ErrorURLNotFound {
+				return "", "", fmt.Errorf("short code belongs to a deleted link")
+			}
+		}
+		return importOverwritten, "", nil
+	case models.ConflictRename:
+		return importRenamed, "", nil
+	default:
+		return importSkipped, "", nil
+	}
+}
+
+// importedURL validates a record and builds the URL it imports as
+func importedURL(record models.ImportRecord) (models.URL, error) {
+	if !utils.ValidateURL(record.URL) {
+		return models.URL{}, models.ErrorInvalidURL
+	}
+
+	tags, err := normalizeTags(record.Tags)
+	if err != nil {
+		return models.URL{}, err
+	}
+	folder, err := normalizeFolder(record.Folder)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	url := models.URL{
+		OriginalURL: utils.PrepareURL(record.URL),
+		Title:       record.Title,
+		Notes:       record.Notes,
+		Tags:        tags,
+		Folder:      folder,
+	}
+	if record.CreatedAt != nil {
+		url.CreatedAt = *record.CreatedAt
+	}
+	if record.Clicks != nil {
+		url.AccessCount = *record.Clicks
+	}
+	return url, nil
+}
+
+// newImportJob builds a job waiting to run
+func newImportJob(source string, format string, conflict string, dryRun bool, path string, ownsFile bool, cli bool) models.ImportJob {
+	now := time.Now()
+	return models.ImportJob{
+		Status:    models.ImportPending,
+		Source:    source,
+		Format:    format,
+		Conflict:  conflict,
+		DryRun:    dryRun,
+		CreatedAt: now,
+		UpdatedAt: now,
+		FilePath:  path,
+		OwnsFile:  ownsFile,
+		CLI:       cli,
+	}
+}
+
+// withProgress fills in the percentage of records a job has processed
+func withProgress(job models.ImportJob) models.ImportJob {
+	switch {
+	case job.Status == models.ImportCompleted:
+		job.Progress = 100
+	case job.Total > 0:
+		job.Progress = math.Round(float64(job.Processed)*1000/float64(job.Total)) / 10
+	}
+	return job
+}
+
+// validImport reports whether a format and conflict policy are supported
+func validImport(format string, conflict string) bool {
+	switch format {
+	case models.ImportCSV, models.ImportJSON, models.ImportNDJSON:
+	default:
+		return false
+	}
+
+	switch conflict {
+	case models.ConflictSkip, models.ConflictOverwrite, models.ConflictRename:
+		return true
+	default:
+		return false
+	}
+}
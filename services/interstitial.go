@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// PreviewShortCodeSuffix asks for the interstitial preview when appended to a short code
+const PreviewShortCodeSuffix = "+"
+
+// RequiresPreview reports whether visitors must see the interstitial preview
+// before being sent to a URL's destination: when they asked for it, when the
+// link always previews, or when untrusted links are forced to
+func (s *RedirectService) RequiresPreview(url models.URL, requested bool) bool {
+	if requested {
+		return true
+	}
+	if url.RedirectOptions != nil && url.RedirectOptions.AlwaysPreview {
+		return true
+	}
+	return s.forcePreviewUntrusted && !s.trustedKeys[url.CreatorKey]
+}
+
+// hashAPIKeys returns the fingerprints of API keys as a set
+func hashAPIKeys(keys []string) map[string]bool {
+	hashes := make(map[string]bool)
+	for _, key := range keys {
+		if hash := utils.HashAPIKey(key); hash != "" {
+			hashes[hash] = true
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPeriodically calls job immediately and then every interval until ctx is cancelled
+func runPeriodically(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// TrashPurger periodically hard-deletes URLs that have been in the trash
+// longer than the retention period
+type TrashPurger struct {
+	service    *URLService
+	retention  time.Duration
+	quarantine time.Duration
+	interval   time.Duration
+}
+
+// NewTrashPurger creates a new instance of TrashPurger
+func NewTrashPurger(service *URLService, retention, quarantine, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		service:    service,
+		retention:  retention,
+		quarantine: quarantine,
+		interval:   interval,
+	}
+}
+
+// Start runs the purger in the background until ctx is cancelled
+func (p *TrashPurger) Start(ctx context.Context) {
+	go runPeriodically(ctx, p.interval, func() {
+		purged, err := p.service.PurgeTrash(time.Now(), p.retention, p.quarantine)
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("Purged %d URLs from the trash", purged)
+		}
+	})
+}
+
+// ClickCompactor periodically deletes raw click events older than the
+// retention period; their hourly and daily rollups are kept
+type ClickCompactor struct {
+	service   *ClickService
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewClickCompactor creates a new instance of ClickCompactor
+func NewClickCompactor(service *ClickService, retention, interval time.Duration) *ClickCompactor {
+	return &ClickCompactor{
+		service:   service,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start runs the compactor in the background until ctx is cancelled
+func (c *ClickCompactor) Start(ctx context.Context) {
+	go runPeriodically(ctx, c.interval, func() {
+		deleted, err := c.service.CompactEvents(time.Now(), c.retention)
+		if err != nil {
+			log.Printf("Error compacting click events: %v", err)
+			return
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d click events older than %s", deleted, c.retention)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+)
+
+// metadataJob asks for the metadata of a URL's destination
+type metadataJob struct {
+	shortCode   string
+	originalURL string
+}
+
+// MetadataService fetches destination page metadata in the background
+type MetadataService struct {
+	repository *repositories.URLRepository
+	cache      *CacheService
+	fetcher    *PageFetcher
+	webhooks   *WebhookService
+	jobs       chan metadataJob
+	workers    int
+}
+
+// NewMetadataService creates a new instance of MetadataService with a queue
+// holding up to queueSize pending fetches
+func NewMetadataService(repository *repositories.URLRepository, cache *CacheService, fetcher *PageFetcher, webhooks *WebhookService, workers, queueSize int) *MetadataService {
+	return &MetadataService{
+		repository: repository,
+		cache:      cache,
+		fetcher:    fetcher,
+		webhooks:   webhooks,
+		jobs:       make(chan metadataJob, queueSize),
+		workers:    workers,
+	}
+}
+
+// Enqueue schedules a metadata fetch for the current destination of a URL.
+// It never blocks; when the queue is full the fetch is dropped.
+func (s *MetadataService) Enqueue(url models.URL) {
+	select {
+	case s.jobs <- metadataJob{shortCode: url.ShortCode, originalURL: url.OriginalURL}:
+	default:
+		log.Printf("Metadata queue full, skipping fetch for %s", url.ShortCode)
+	}
+}
+
+// Start runs the workers in the background until ctx is cancelled
+func (s *MetadataService) Start(ctx context.Context) {
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					s.process(ctx, job)
+				}
+			}
+		}()
+	}
+}
+
+// process fetches a destination and stores the result on the URL. Failures
+// are stored too, so the dashboard can tell a failed fetch from a pending one.
+func (s *MetadataService) process(ctx context.Context, job metadataJob) {
+	page, err := s.fetcher.Fetch(ctx, job.originalURL)
+	if err != nil {
+		log.Printf("Error fetching metadata for %s: %v", job.shortCode, err)
+		page = models.PageMetadata{Error: err.Error()}
+	}
+	page.FetchedURL = job.originalURL
+	page.FetchedAt = time.Now()
+
+	// Update in database; skipped if the destination changed while fetching
+	updatedURL, err := s.repository.SetPageMetadata(job.shortCode, job.originalURL, page)
+	if err != nil {
+		if err != models.ErrorURLNotFound {
+			log.Printf("Error storing metadata for %s: %v", job.shortCode, err)
+		}
+		return
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	if s.webhooks != nil {
+		s.webhooks.Emit(models.EventLinkUpdated, updatedURL)
+	}
+}
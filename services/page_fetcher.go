@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxPageRedirects     = 5
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// PageFetcher downloads destination pages and extracts their metadata
+type PageFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewPageFetcher creates a new instance of PageFetcher. Each fetch must finish
+// within timeout, only the first maxBytes of a page are read and allowPrivate
+// is passed on to newOutboundClient.
+func NewPageFetcher(timeout time.Duration, maxBytes int64, allowPrivate bool) *PageFetcher {
+	return &PageFetcher{
+		client:   newOutboundClient(timeout, maxPageRedirects, allowPrivate),
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch downloads a page and extracts its title, description, favicon and Open Graph properties
+func (f *PageFetcher) Fetch(ctx context.Context, pageURL string) (models.PageMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return models.PageMetadata{}, err
+	}
+	req.Header.Set("User-Agent", "url-shortener-metadata/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return models.PageMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return models.PageMetadata{}, fmt.Errorf("destination returned %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return models.PageMetadata{}, fmt.Errorf("destination is not an HTML page (%s)", contentType)
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return models.PageMetadata{}, err
+	}
+
+	// Resolve relative links against the final URL, after redirects
+	return parsePage(body, resp.Request.URL), nil
+}
+
+// parsePage extracts metadata from the head of an HTML document
+func parsePage(body io.Reader, base *url.URL) models.PageMetadata {
+	var page models.PageMetadata
+	var icon, fallbackIcon string
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(body)
+scan:
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		if tokenType == html.TextToken {
+			if inTitle && page.Title == "" {
+				page.Title = strings.Join(strings.Fields(token.Data), " ")
+			}
+			continue
+		}
+		if tokenType == html.EndTagToken {
+			if token.Data == "title" {
+				inTitle = false
+			}
+			if token.Data == "head" {
+				break scan
+			}
+			continue
+		}
+
+		switch token.Data {
+		case "body":
+			// Metadata only lives in the head
+			break scan
+		case "title":
+			inTitle = tokenType == html.StartTagToken
+		case "meta":
+			name := strings.ToLower(attr(token, "name"))
+			property := strings.ToLower(attr(token, "property"))
+			content := strings.TrimSpace(attr(token, "content"))
+			switch {
+			case name == "description" && page.Description == "":
+				page.Description = content
+			case strings.HasPrefix(property, "og:"), strings.HasPrefix(name, "og:"):
+				key := strings.TrimPrefix(property+name, "og:")
+				if key == "" || strings.ContainsAny(key, ".$") || content == "" {
+					continue
+				}
+				if page.OpenGraph == nil {
+					page.OpenGraph = make(map[string]string)
+				}
+				if _, seen := page.OpenGraph[key]; !seen {
+					page.OpenGraph[key] = content
+				}
+			}
+		case "link":
+			rel := strings.Fields(strings.ToLower(attr(token, "rel")))
+			href := resolveLink(base, attr(token, "href"))
+			for _, value := range rel {
+				switch {
+				case value == "icon" && icon == "":
+					icon = href
+				case value == "apple-touch-icon" && fallbackIcon == "":
+					fallbackIcon = href
+				}
+			}
+		}
+	}
+
+	page.Title = truncate(page.Title, maxTitleLength)
+	page.Description = truncate(page.Description, maxDescriptionLength)
+	if image, ok := page.OpenGraph["image"]; ok {
+		page.OpenGraph["image"] = resolveLink(base, image)
+	}
+
+	// Browsers look for /favicon.ico when a page declares no icon
+	switch {
+	case icon != "":
+		page.FaviconURL = icon
+	case fallbackIcon != "":
+		page.FaviconURL = fallbackIcon
+	default:
+		page.FaviconURL = resolveLink(base, "/favicon.ico")
+	}
+
+	return page
+}
+
+// attr returns the value of an attribute of a token
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// resolveLink resolves a possibly relative link against the page URL
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// truncate shortens s to at most max runes
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
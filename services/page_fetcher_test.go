@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>
+    Launch   Week
+  </title>
+  <meta name="description" content=" Everything we shipped ">
+  <meta property="og:title" content="Launch Week 2024">
+  <meta property="og:image" content="/images/cover.png">
+  <meta property="og:bad.key" content="ignored">
+  <link rel="apple-touch-icon" href="/touch.png">
+  <link rel="shortcut icon" href="/static/icon.ico">
+</head>
+<body><title>Not the title</title></body>
+</html>`
+
+// newTestSite serves the pages used by the fetcher tests
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, testPage)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		// The title comes after the first kilobyte
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><!--"+strings.Repeat("x", 2048)+"--><title>Too late</title></head></html>")
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		remaining, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if remaining == 0 {
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(remaining-1), http.StatusFound)
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPageFetcherParsesMetadata(t *testing.T) {
+	server := newTestSite(t)
+	fetcher := NewPageFetcher(5*time.Second, 64*1024, true)
+
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	if page.Title != "Launch Week" {
+		t.Errorf("Title = %q, want %q", page.Title, "Launch Week")
+	}
+	if page.Description != "Everything we shipped" {
+		t.Errorf("Description = %q, want %q", page.Description, "Everything we shipped")
+	}
+	if got := page.OpenGraph["title"]; got != "Launch Week 2024" {
+		t.Errorf("og:title = %q, want %q", got, "Launch Week 2024")
+	}
+	if got, want := page.OpenGraph["image"], server.URL+"/images/cover.png"; got != want {
+		t.Errorf("og:image = %q, want %q", got, want)
+	}
+	if _, ok := page.OpenGraph["bad.key"]; ok {
+		t.Errorf("og property with '.' in its name was kept")
+	}
+	if got, want := page.FaviconURL, server.URL+"/static/icon.ico"; got != want {
+		t.Errorf("FaviconURL = %q, want %q", got, want)
+	}
+}
+
+func TestPageFetcherStopsAtMaxBytes(t *testing.T) {
+	server := newTestSite(t)
+
+	page, err := NewPageFetcher(5*time.Second, 1024, true).Fetch(context.Background(), server.URL+"/large")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if page.Title != "" {
+		t.Errorf("Title = %q, want nothing read past maxBytes", page.Title)
+	}
+
+	page, err = NewPageFetcher(5*time.Second, 64*1024, true).Fetch(context.Background(), server.URL+"/large")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if page.Title != "Too late" {
+		t.Errorf("Title = %q, want %q with a larger limit", page.Title, "Too late")
+	}
+}
+
+func TestPageFetcherFollowsRedirectsUpToTheCap(t *testing.T) {
+	server := newTestSite(t)
+	fetcher := NewPageFetcher(5*time.Second, 64*1024, true)
+
+	// /redirect/n takes n+1 redirects to reach /page
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/redirect/"+strconv.Itoa(maxPageRedirects-2))
+	if err != nil {
+		t.Fatalf("Fetch with %d redirects: %v", maxPageRedirects-1, err)
+	}
+	if page.Title != "Launch Week" {
+		t.Errorf("Title = %q after redirects, want %q", page.Title, "Launch Week")
+	}
+	if got, want := page.FaviconURL, server.URL+"/static/icon.ico"; got != want {
+		t.Errorf("FaviconURL = %q, want it resolved against the final URL %q", got, want)
+	}
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/redirect/"+strconv.Itoa(maxPageRedirects-1))
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("Fetch with %d redirects: err = %v, want the redirect cap", maxPageRedirects, err)
+	}
+}
+
+func TestPageFetcherRejectsNonHTML(t *testing.T) {
+	server := newTestSite(t)
+
+	_, err := NewPageFetcher(5*time.Second, 64*1024, true).Fetch(context.Background(), server.URL+"/image")
+	if err == nil {
+		t.Errorf("Fetch of an image succeeded, want an error")
+	}
+}
+
+func TestPageFetcherRefusesLoopback(t *testing.T) {
+	server := newTestSite(t)
+
+	_, err := NewPageFetcher(5*time.Second, 64*1024, false).Fetch(context.Background(), server.URL+"/page")
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("Fetch of a loopback server: err = %v, want %v", err, errPrivateAddress)
+	}
+}
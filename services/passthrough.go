@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// applyRedirectOptions carries the extra path and query of a redirect request
+// over to the destination, and injects the link's fixed UTM parameters
+func applyRedirectOptions(destination string, options *models.RedirectOptions, extraPath string, query neturl.Values) string {
+	if options == nil {
+		return destination
+	}
+
+	target, err := neturl.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	// Append extra path segments, e.g. /r/docs/api/v2 -> https://docs.example.com/api/v2
+	if options.ForwardPath && extraPath != "" {
+		target.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(extraPath, "/")
+		target.RawPath = ""
+	}
+
+	params := target.Query()
+
+	// Fixed UTM parameters always apply
+	for key, value := range options.UTMParams {
+		params.Set(key, value)
+	}
+
+	// Forward incoming parameters
+	switch options.ForwardQuery {
+	case models.QueryForwardMerge:
+		for key, values := range query {
+			if _, exists := params[key]; !exists {
+				params[key] = values
+			}
+		}
+	case models.QueryForwardOverride:
+		for key, values := range query {
+			params[key] = values
+		}
+	}
+
+	target.RawQuery = params.Encode()
+	return target.String()
+}
+
+// validateRedirectOptions checks the redirect options of a link
+func validateRedirectOptions(options *models.RedirectOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	switch options.ForwardQuery {
+	case models.QueryForwardNone, models.QueryForwardMerge, models.QueryForwardOverride:
+	default:
+		return fmt.Errorf("%w: unknown forwardQuery mode %q", models.ErrorInvalidOptions, options.ForwardQuery)
+	}
+
+	for key := range options.UTMParams {
+		if !strings.HasPrefix(key, "utm_") {
+			return fmt.Errorf("%w: %q is not a UTM parameter", models.ErrorInvalidOptions, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// RedirectService decides where a short link sends a given visitor
+type RedirectService struct {
+	geoIP                 *config.GeoIPDatabase
+	notYetAvailable       models.UnavailableResponse
+	trustedKeys           map[string]bool // Fingerprints of trusted API keys
+	forcePreviewUntrusted bool
+}
+
+// NewRedirectService creates a new instance of RedirectService. When
+// forcePreviewUntrusted is set, links not created with one of trustedKeys
+// always show the interstitial preview.
+func NewRedirectService(geoIP *config.GeoIPDatabase, notYetAvailable models.UnavailableResponse, trustedKeys []string, forcePreviewUntrusted bool) *RedirectService {
+	return &RedirectService{
+		geoIP:                 geoIP,
+		notYetAvailable:       notYetAvailable,
+		trustedKeys:           hashAPIKeys(trustedKeys),
+		forcePreviewUntrusted: forcePreviewUntrusted,
+	}
+}
+
+// NotYetAvailable returns the response to serve when a link's activation
+// time has not been reached yet
+func (s *RedirectService) NotYetAvailable(url models.URL, now time.Time) (models.UnavailableResponse, bool) {
+	if url.ActiveFrom == nil || !now.Before(*url.ActiveFrom) {
+		return models.UnavailableResponse{}, false
+	}
+	return s.notYetAvailable, true
+}
+
+// ResolveDestination returns the destination of the first targeting rule
+// matching the visitor, then of the visitor's A/B variant, falling back to
+// the link's original URL. The name of the chosen variant, if any, is also returned.
+func (s *RedirectService) ResolveDestination(url models.URL, visitor models.Visitor) (string, string) {
+	if target, ok := s.matchRule(url.Rules, visitor); ok {
+		return target, ""
+	}
+
+	if variant, ok := chooseVariant(url, visitor); ok {
+		return variant.URL, variant.Name
+	}
+
+	// A due change may not have been applied by the scheduler yet
+	if change, ok := latestDueChange(url.ScheduledChanges, visitor.Time); ok {
+		return change.URL, ""
+	}
+
+	return url.OriginalURL, ""
+}
+
+// BuildTarget resolves the destination for a visitor and applies the link's
+// redirect options for the request's extra path and query parameters.
+// The name of the chosen variant, if any, is also returned.
+func (s *RedirectService) BuildTarget(url models.URL, visitor models.Visitor, extraPath string, query neturl.Values) (string, string) {
+	destination, variant := s.ResolveDestination(url, visitor)
+	return applyRedirectOptions(destination, url.RedirectOptions, extraPath, query), variant
+}
+
+// matchRule returns the destination of the first rule matching the visitor
+func (s *RedirectService) matchRule(rules []models.TargetingRule, visitor models.Visitor) (string, bool) {
+	if len(rules) == 0 {
+		return "", false
+	}
+
+	// Look up the country lazily, only if a rule needs it
+	country := ""
+	countryResolved := false
+
+	platform := utils.DetectPlatform(visitor.UserAgent)
+	languages := utils.ParseAcceptLanguage(visitor.AcceptLanguage)
+
+	for _, rule := range rules {
+		if len(rule.Platforms) > 0 && !containsFold(rule.Platforms, platform) {
+			continue
+		}
+		if len(rule.Languages) > 0 && !matchesLanguage(rule.Languages, languages) {
+			continue
+		}
+		if len(rule.Countries) > 0 {
+			if !countryResolved {
+				country = s.geoIP.LookupCountry(visitor.IP)
+				countryResolved = true
+			}
+			if country == "" || !containsFold(rule.Countries, country) {
+				continue
+			}
+		}
+		if rule.TimeWindow != nil && !inTimeWindow(*rule.TimeWindow, visitor.Time) {
+			continue
+		}
+		return rule.URL, true
+	}
+
+	return "", false
+}
+
+// validateRules checks targeting rules and normalises their destinations
+func validateRules(rules []models.TargetingRule) ([]models.TargetingRule, error) {
+	validated := make([]models.TargetingRule, 0, len(rules))
+	for i, rule := range rules {
+		if !utils.ValidateURL(rule.URL) {
+			return nil, fmt.Errorf("%w: rule %d has an invalid URL", models.ErrorInvalidRule, i+1)
+		}
+		rule.URL = utils.PrepareURL(rule.URL)
+
+		for _, platform := range rule.Platforms {
+			if !containsFold(utils.KnownPlatforms, platform) {
+				return nil, fmt.Errorf("%w: rule %d has unknown platform %q", models.ErrorInvalidRule, i+1, platform)
+			}
+		}
+		for _, country := range rule.Countries {
+			if len(country) != 2 {
+				return nil, fmt.Errorf("%w: rule %d has invalid country code %q", models.ErrorInvalidRule, i+1, country)
+			}
+		}
+		if rule.TimeWindow != nil {
+			if _, err := parseClock(rule.TimeWindow.Start); err != nil {
+				return nil, fmt.Errorf("%w: rule %d has invalid start time", models.ErrorInvalidRule, i+1)
+			}
+			if _, err := parseClock(rule.TimeWindow.End); err != nil {
+				return nil, fmt.Errorf("%w: rule %d has invalid end time", models.ErrorInvalidRule, i+1)
+			}
+			if _, err := time.LoadLocation(rule.TimeWindow.Timezone); err != nil {
+				return nil, fmt.Errorf("%w: rule %d has unknown timezone", models.ErrorInvalidRule, i+1)
+			}
+		}
+
+		validated = append(validated, rule)
+	}
+	return validated, nil
+}
+
+// matchesLanguage reports whether any accepted language matches a rule language,
+// so a rule for "en" matches "en-GB" but a rule for "en-GB" does not match "en"
+func matchesLanguage(ruleLanguages []string, accepted []string) bool {
+	for _, want := range ruleLanguages {
+		want = strings.ToLower(want)
+		for _, have := range accepted {
+			if have == want || strings.HasPrefix(have, want+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inTimeWindow reports whether t falls inside the daily window
+func inTimeWindow(window models.TimeWindow, t time.Time) bool {
+	location, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := parseClock(window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(window.End)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// Window wraps past midnight, e.g. 22:00-06:00
+	return minute >= start || minute < end
+}
+
+// parseClock parses an HH:MM time into minutes past midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// containsFold reports whether values contains target, ignoring case
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
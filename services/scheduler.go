@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// Scheduler periodically applies scheduled destination changes
+type Scheduler struct {
+	service  *URLService
+	interval time.Duration
+}
+
+// NewScheduler creates a new instance of Scheduler
+func NewScheduler(service *URLService, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go runPeriodically(ctx, s.interval, func() {
+		applied, err := s.service.ApplyScheduledChanges(time.Now())
+		if err != nil {
+			log.Printf("Error applying scheduled changes: %v", err)
+			return
+		}
+		if applied > 0 {
+			log.Printf("Applied scheduled destination changes to %d URLs", applied)
+		}
+	})
+}
+
+// latestDueChange returns the most recent scheduled change that is due at now
+// and has not been applied yet
+func latestDueChange(changes []models.ScheduledChange, now time.Time) (models.ScheduledChange, bool) {
+	var latest models.ScheduledChange
+	found := false
+	for _, change := range changes {
+		if change.Applied || change.At.After(now) {
+			continue
+		}
+		if !found || change.At.After(latest.At) {
+			latest = change
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// validateSchedule checks scheduled changes and normalises their destinations.
+// Changes that are already stored keep their applied flag, so sending back a
+// schedule as it was read does not run its changes again; new changes must
+// not be due yet.
+func validateSchedule(changes []models.ScheduledChange, stored []models.ScheduledChange, now time.Time) ([]models.ScheduledChange, error) {
+	applied := make(map[string]bool)
+	for _, change := range stored {
+		if change.Applied {
+			applied[scheduleKey(change)] = true
+		}
+	}
+
+	validated := make([]models.ScheduledChange, 0, len(changes))
+	for i, change := range changes {
+		if change.At.IsZero() {
+			return nil, fmt.Errorf("%w: change %d has no time", models.ErrorInvalidSchedule, i+1)
+		}
+		if !utils.ValidateURL(change.URL) {
+			return nil, fmt.Errorf("%w: change %d has an invalid URL", models.ErrorInvalidSchedule, i+1)
+		}
+		change.URL = utils.PrepareURL(change.URL)
+
+		change.Applied = applied[scheduleKey(change)]
+		if !change.Applied && change.At.Before(now) {
+			return nil, fmt.Errorf("%w: change %d is in the past", models.ErrorInvalidSchedule, i+1)
+		}
+		validated = append(validated, change)
+	}
+	return validated, nil
+}
+
+// scheduleKey identifies a scheduled change by its time, at the millisecond
+// precision it is stored with, and its destination
+func scheduleKey(change models.ScheduledChange) string {
+	return change.At.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano) + " " + change.URL
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+const (
+	// DefaultSearchLimit is the page size used when none is requested
+	DefaultSearchLimit = 20
+	// MaxSearchLimit is the largest page size a search can request
+	MaxSearchLimit = 100
+)
+
+// SearchURLs finds URLs whose destination, short code, title, notes or tags
+// match the query and returns the requested page together with the total
+// number of matches
+func (s *URLService) SearchURLs(query string, page, limit int) ([]models.URL, int64, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || page < 1 || limit < 1 || limit > MaxSearchLimit {
+		return nil, 0, models.ErrorInvalidSearch
+	}
+
+	skip := int64(page-1) * int64(limit)
+	return s.repository.SearchURLs(query, skip, int64(limit))
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// ShortCodeSequence is the name of the sequence backing sequential strategies
+const ShortCodeSequence = "short_code"
+
+// NewShortCodeGenerator creates the short code generator selected in the configuration
+func NewShortCodeGenerator(conf *config.Config, ids *IDAllocator) (utils.ShortCodeGenerator, error) {
+	alphabet := conf.ShortCodeAlphabet
+	switch alphabet {
+	case "", "default":
+		alphabet = utils.DefaultAlphabet
+	case "unambiguous":
+		alphabet = utils.UnambiguousAlphabet
+	}
+
+	switch conf.ShortCodeStrategy {
+	case "", "random":
+		return utils.NewRandomGenerator(alphabet, conf.ShortCodeLength, conf.ShortCodeGrowthThreshold, conf.ShortCodeGrowthWindow)
+	case "counter":
+		return utils.NewCounterGenerator(alphabet, ids.Next)
+	case "obfuscated":
+		return utils.NewObfuscatedGenerator(alphabet, conf.ShortCodeSalt, conf.ShortCodeLength, ids.Next)
+	default:
+		return nil, fmt.Errorf("unknown short code strategy %q", conf.ShortCodeStrategy)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// SocialPreview returns what link-unfurling crawlers should be shown for a
+// URL. It is only set when the URL defines its own preview; fields the URL
+// leaves empty fall back to the metadata fetched from its destination.
+func (s *RedirectService) SocialPreview(url models.URL) (models.SocialPreview, bool) {
+	if url.SocialPreview == nil {
+		return models.SocialPreview{}, false
+	}
+
+	preview := *url.SocialPreview
+	if page := url.Page; page != nil {
+		if preview.Title == "" {
+			preview.Title = firstNonEmpty(page.OpenGraph["title"], page.Title)
+		}
+		if preview.Description == "" {
+			preview.Description = firstNonEmpty(page.OpenGraph["description"], page.Description)
+		}
+		if preview.Image == "" {
+			preview.Image = page.OpenGraph["image"]
+		}
+	}
+
+	return preview, true
+}
+
+// validateSocialPreview checks the limits of a social preview and returns it
+// trimmed, or nil when it sets nothing
+func validateSocialPreview(preview *models.SocialPreview) (*models.SocialPreview, error) {
+	if preview == nil {
+		return nil, nil
+	}
+
+	trimmed := models.SocialPreview{
+		Title:       strings.TrimSpace(preview.Title),
+		Description: strings.TrimSpace(preview.Description),
+		Image:       strings.TrimSpace(preview.Image),
+	}
+	if trimmed == (models.SocialPreview{}) {
+		return nil, nil
+	}
+
+	if utf8.RuneCountInString(trimmed.Title) > maxTitleLength {
+		return nil, fmt.Errorf("%w: title is longer than %d characters", models.ErrorInvalidPreview, maxTitleLength)
+	}
+	if utf8.RuneCountInString(trimmed.Description) > maxDescriptionLength {
+		return nil, fmt.Errorf("%w: description is longer than %d characters", models.ErrorInvalidPreview, maxDescriptionLength)
+	}
+	if trimmed.Image != "" {
+		image, err := url.Parse(trimmed.Image)
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") || image.Host == "" {
+			return nil, fmt.Errorf("%w: image must be an absolute http(s) URL", models.ErrorInvalidPreview)
+		}
+	}
+
+	return &trimmed, nil
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
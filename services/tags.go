@@ -0,0 +1,193 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+)
+
+// normalizeTags trims and lower-cases tags and drops duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// normalizeTag trims and lower-cases a tag
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > 50 {
+		return "", models.ErrorInvalidTag
+	}
+	return tag, nil
+}
+
+// normalizeFolder trims a folder name; an empty name means no folder
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if utf8.RuneCountInString(folder) > 100 {
+		return "", models.ErrorInvalidFolder
+	}
+	return folder, nil
+}
+
+// AddTags adds tags to a URL
+func (s *URLService) AddTags(shortCode string, tags []string) (models.URL, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, err := s.repository.AddTags(shortCode, tags)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// RemoveTag removes a tag from a URL
+func (s *URLService) RemoveTag(shortCode string, tag string) (models.URL, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, err := s.repository.RemoveTag(shortCode, tag)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// RenameTag renames a tag on every URL and returns how many URLs changed
+func (s *URLService) RenameTag(oldTag, newTag string) (int, error) {
+	oldTag, err := normalizeTag(oldTag)
+	if err != nil {
+		return 0, err
+	}
+	newTag, err = normalizeTag(newTag)
+	if err != nil {
+		return 0, err
+	}
+	if oldTag == newTag {
+		return 0, nil
+	}
+
+	shortCodes, err := s.repository.RenameTag(oldTag, newTag)
+	s.changedAll(shortCodes)
+	return len(shortCodes), err
+}
+
+// DeleteTag removes a tag from every URL and returns how many URLs changed
+func (s *URLService) DeleteTag(tag string) (int, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	shortCodes, err := s.repository.DeleteTag(tag)
+	s.changedAll(shortCodes)
+	return len(shortCodes), err
+}
+
+// RenameFolder moves every URL in a folder to another and returns how many URLs changed
+func (s *URLService) RenameFolder(oldFolder, newFolder string) (int, error) {
+	oldFolder, err := normalizeFolder(oldFolder)
+	if err != nil {
+		return 0, err
+	}
+	newFolder, err = normalizeFolder(newFolder)
+	if err != nil {
+		return 0, err
+	}
+	if newFolder == "" {
+		return s.DeleteFolder(oldFolder)
+	}
+	if oldFolder == "" || oldFolder == newFolder {
+		return 0, nil
+	}
+
+	shortCodes, err := s.repository.RenameFolder(oldFolder, newFolder)
+	s.changedAll(shortCodes)
+	return len(shortCodes), err
+}
+
+// DeleteFolder takes every URL out of a folder and returns how many URLs changed
+func (s *URLService) DeleteFolder(folder string) (int, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return 0, err
+	}
+	if folder == "" {
+		return 0, nil
+	}
+
+	shortCodes, err := s.repository.DeleteFolder(folder)
+	s.changedAll(shortCodes)
+	return len(shortCodes), err
+}
+
+// GetTagStats retrieves link counts and click totals per tag
+func (s *URLService) GetTagStats() ([]models.TagStats, error) {
+	return s.repository.GetTagStats()
+}
+
+// GetFolderStats retrieves link counts and click totals per folder
+func (s *URLService) GetFolderStats() ([]models.FolderStats, error) {
+	return s.repository.GetFolderStats()
+}
+
+// changedAll removes URLs changed in bulk from the cache and notifies
+// webhook subscribers of each
+func (s *URLService) changedAll(shortCodes []string) {
+	if s.cache != nil {
+		for _, shortCode := range shortCodes {
+			s.cache.InvalidateURL(shortCode)
+		}
+	}
+
+	if s.webhooks == nil || len(shortCodes) == 0 {
+		return
+	}
+	urls, err := s.repository.GetURLsByShortCodes(shortCodes)
+	if err != nil {
+		log.Printf("Error loading URLs changed in bulk: %v", err)
+		return
+	}
+	for _, url := range urls {
+		s.webhooks.Emit(models.EventLinkUpdated, url)
+	}
+}
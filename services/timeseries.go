@@ -0,0 +1,135 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// maxTimeSeriesPoints bounds the number of hours or days in one time series
+const maxTimeSeriesPoints = 1000
+
+// topBreakdownSize is the number of referrers, countries and platforms returned
+const topBreakdownSize = 10
+
+// TimeSeries returns a link's clicks per hour or day in [from, to), read from
+// its rollups. from is rounded down and to up to whole hours or UTC days, and
+// periods without clicks are included with zero counts. Unique visitors are
+// estimated from the HyperLogLog registers stored in each rollup. Visitor
+// hashes are salted per day, so registers are only merged within a UTC day
+// and the total is the sum of the daily estimates.
+func (s *ClickService) TimeSeries(shortCode string, granularity string, from time.Time, to time.Time) (models.TimeSeriesResponse, error) {
+	var step time.Duration
+	switch granularity {
+	case models.GranularityHour:
+		step = time.Hour
+	case models.GranularityDay:
+		step = 24 * time.Hour
+	default:
+		return models.TimeSeriesResponse{}, models.ErrorInvalidTimeSeries
+	}
+
+	from = from.UTC().Truncate(step)
+	if rounded := to.UTC().Truncate(step); rounded.Before(to) {
+		to = rounded.Add(step)
+	} else {
+		to = rounded
+	}
+	if !from.Before(to) || to.Sub(from)/step > maxTimeSeriesPoints {
+		return models.TimeSeriesResponse{}, models.ErrorInvalidTimeSeries
+	}
+
+	rollups, err := s.rollups.GetRollups(shortCode, granularity, from, to)
+	if err != nil {
+		return models.TimeSeriesResponse{}, err
+	}
+
+	response := models.TimeSeriesResponse{
+		ShortCode:   shortCode,
+		Granularity: granularity,
+		From:        from,
+		To:          to,
+		Points:      []models.TimeSeriesPoint{},
+	}
+	days := make(map[time.Time]*utils.HyperLogLog)
+	referrers := make(map[string]int64)
+	countries := make(map[string]int64)
+	platforms := make(map[string]int64)
+
+	next := 0
+	for start := from; start.Before(to); start = start.Add(step) {
+		point := models.TimeSeriesPoint{Start: start}
+		if next < len(rollups) && rollups[next].Start.Equal(start) {
+			rollup := rollups[next]
+			point.Clicks = rollup.Clicks
+			point.Bots = rollup.Bots
+			day := start.Truncate(24 * time.Hour)
+			if days[day] == nil {
+				days[day] = utils.NewHyperLogLog()
+			}
+			point.Uniques = countVisitors(rollup.Visitors, days[day])
+			addCounts(referrers, rollup.Referrers)
+			addCounts(countries, rollup.Countries)
+			addCounts(platforms, rollup.Platforms)
+			next++
+		}
+
+		response.Clicks += point.Clicks
+		response.Bots += point.Bots
+		response.Points = append(response.Points, point)
+	}
+
+	for _, visitors := range days {
+		response.Uniques += visitors.Count()
+	}
+	response.Referrers = topCounts(referrers, topBreakdownSize)
+	response.Countries = topCounts(countries, topBreakdownSize)
+	response.Platforms = topCounts(platforms, topBreakdownSize)
+	return response, nil
+}
+
+// countVisitors estimates the visitors of a rollup from its HyperLogLog
+// registers, also merging them into the sketch of its day
+func countVisitors(registers map[string]int32, day *utils.HyperLogLog) int64 {
+	sketch := utils.NewHyperLogLog()
+	for key, rank := range registers {
+		index, err := strconv.Atoi(key)
+		if err != nil || rank < 0 || rank > math.MaxUint8 {
+			continue
+		}
+		sketch.AddRegister(index, uint8(rank))
+		day.AddRegister(index, uint8(rank))
+	}
+	return sketch.Count()
+}
+
+// addCounts adds the counts of src to dst
+func addCounts(dst map[string]int64, src map[string]int64) {
+	for name, count := range src {
+		dst[name] += count
+	}
+}
+
+// topCounts returns the n largest counts, largest first and ties by name
+func topCounts(counts map[string]int64, n int) []models.CountEntry {
+	entries := make([]models.CountEntry, 0, len(counts))
+	for name, count := range counts {
+		entries = append(entries, models.CountEntry{Name: name, Count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// dateLayout formats the UTC day unique visitors are counted in
+const dateLayout = "2006-01-02"
+
+// maxLocalUniqueVisitorSketches bounds the in-process sketches kept without
+// Redis. Sketches of links with few visitors take a few hundred bytes and
+// none takes more than 4 KB, so the counts never use more than 200 MB.
+const maxLocalUniqueVisitorSketches = 50000
+
+// UniqueVisitorCounter approximately counts unique visitors per link per day.
+// Visitors are identified by an HMAC of their IP and User-Agent keyed with a
+// random salt that changes daily and is never stored past the next day, so
+// neither the raw values nor a stable per-person identifier are kept. Counts
+// live in Redis HyperLogLogs when available, shared by every replica, and in
+// in-process sketches otherwise, which are lost on restart. In-process, the
+// oldest days are forgotten early when there are too many sketches.
+type UniqueVisitorCounter struct {
+	redis     *config.RedisCache
+	retention int // Days counts are kept for
+
+	mu     sync.Mutex
+	salts  map[string][]byte
+	local  map[uniqueVisitorKey]*utils.HyperLogLog
+	pruned string // Last day local sketches were pruned on
+	full   bool   // Whether today's sketches alone reached the limit
+}
+
+// uniqueVisitorKey identifies the in-process sketch of one link on one day
+type uniqueVisitorKey struct {
+	shortCode string
+	date      string
+}
+
+// NewUniqueVisitorCounter creates a new instance of UniqueVisitorCounter
+func NewUniqueVisitorCounter(redis *config.RedisCache, retention int) *UniqueVisitorCounter {
+	return &UniqueVisitorCounter{
+		redis:     redis,
+		retention: retention,
+		salts:     make(map[string][]byte),
+		local:     make(map[uniqueVisitorKey]*utils.HyperLogLog),
+	}
+}
+
+// Add counts a visit to a link and returns the visitor's hash for the day, so
+// the visit can also be added to sketches kept elsewhere. ok is false when the
+// visitor could not be hashed.
+func (c *UniqueVisitorCounter) Add(shortCode string, visitor models.Visitor) (hash uint64, ok bool) {
+	date := visitor.Time.UTC().Format(dateLayout)
+
+	salt, err := c.salt(date)
+	if err != nil {
+		log.Printf("Error getting unique visitor salt: %v", err)
+		return 0, false
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(visitor.IP + "\n" + visitor.UserAgent))
+	sum := mac.Sum(nil)
+	hash = binary.BigEndian.Uint64(sum)
+
+	if c.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		key := uniqueVisitorRedisKey(shortCode, date)
+		pipe := c.redis.Client.Pipeline()
+		pipe.PFAdd(ctx, key, hex.EncodeToString(sum[:16]))
+		pipe.Expire(ctx, key, time.Duration(c.retention+1)*24*time.Hour)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error counting unique visitor: %v", err)
+		}
+		return hash, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(date)
+	key := uniqueVisitorKey{shortCode: shortCode, date: date}
+	sketch, ok := c.local[key]
+	if !ok {
+		if !c.makeRoom(date) {
+			return hash, true
+		}
+		sketch = utils.NewHyperLogLog()
+		c.local[key] = sketch
+	}
+	sketch.Add(hash)
+	return hash, true
+}
+
+// Stats returns the unique visitors of a link over the last days, today included
+func (c *UniqueVisitorCounter) Stats(shortCode string, days int, now time.Time) (models.UniqueVisitorStats, error) {
+	if days > c.retention {
+		days = c.retention
+	}
+	if days < 1 {
+		days = 1
+	}
+
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = now.UTC().AddDate(0, 0, -i).Format(dateLayout)
+	}
+
+	stats := models.UniqueVisitorStats{
+		Daily: make([]models.DailyUniqueVisitors, days),
+	}
+
+	if c.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		pipe := c.redis.Client.Pipeline()
+		counts := make([]*redis.IntCmd, days)
+		for i, date := range dates {
+			counts[i] = pipe.PFCount(ctx, uniqueVisitorRedisKey(shortCode, date))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return models.UniqueVisitorStats{}, err
+		}
+		for i, date := range dates {
+			stats.Daily[i] = models.DailyUniqueVisitors{Date: date, Count: counts[i].Val()}
+		}
+	} else {
+		c.mu.Lock()
+		for i, date := range dates {
+			stats.Daily[i] = models.DailyUniqueVisitors{Date: date}
+			if sketch, ok := c.local[uniqueVisitorKey{shortCode: shortCode, date: date}]; ok {
+				stats.Daily[i].Count = sketch.Count()
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	stats.Today = stats.Daily[0].Count
+	return stats, nil
+}
+
+// salt returns the secret of a day, creating it on first use. With Redis the
+// salt is shared so every replica hashes a visitor the same way.
+func (c *UniqueVisitorCounter) salt(date string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if salt, ok := c.salts[date]; ok {
+		return salt, nil
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	if c.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		// The first replica to ask picks the salt; it expires once the day is over
+		key := "uv:salt:" + date
+		if err := c.redis.Client.SetNX(ctx, key, hex.EncodeToString(salt), 48*time.Hour).Err(); err != nil {
+			return nil, err
+		}
+		stored, err := c.redis.Client.Get(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		salt, err = hex.DecodeString(stored)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Forget salts of earlier days
+	for day := range c.salts {
+		if day < date {
+			delete(c.salts, day)
+		}
+	}
+	c.salts[date] = salt
+	return salt, nil
+}
+
+// prune drops in-process sketches older than the retention, once per day
+func (c *UniqueVisitorCounter) prune(date string) {
+	if c.pruned == date {
+		return
+	}
+	c.pruned = date
+	c.full = false
+
+	today, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return
+	}
+	oldest := today.AddDate(0, 0, -c.retention+1).Format(dateLayout)
+	for key := range c.local {
+		if key.date < oldest {
+			delete(c.local, key)
+		}
+	}
+}
+
+// makeRoom drops the sketches of the oldest days until a new sketch fits
+// under maxLocalUniqueVisitorSketches, and reports whether it does. Today's
+// sketches are never dropped, so once they alone fill the limit, links first
+// visited later in the day are not counted until the next day.
+func (c *UniqueVisitorCounter) makeRoom(date string) bool {
+	// Only today's sketches are left once full is set; prune clears it the next day
+	if c.full {
+		return false
+	}
+
+	for len(c.local) >= maxLocalUniqueVisitorSketches {
+		oldest := date
+		for key := range c.local {
+			if key.date < oldest {
+				oldest = key.date
+			}
+		}
+		if oldest == date {
+			c.full = true
+			log.Printf("Warning: %d links were visited today; unique visitors of further links are not counted without Redis", len(c.local))
+			return false
+		}
+		for key := range c.local {
+			if key.date == oldest {
+				delete(c.local, key)
+			}
+		}
+	}
+	return true
+}
+
+// uniqueVisitorRedisKey returns the Redis key of a link's HyperLogLog for a day
+func uniqueVisitorRedisKey(shortCode string, date string) string {
+	return "uv:" + shortCode + ":" + date
+}
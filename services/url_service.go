@@ -2,63 +2,190 @@ package services
 
 import (
 	"log"
+	"strings"
+	"time"
 
 	"github.com/askarbtw/url-shortener-golang/models"
 	"github.com/askarbtw/url-shortener-golang/repositories"
 	"github.com/askarbtw/url-shortener-golang/utils"
 )
 
+// reservedAliases are path segments under /shorten that cannot be used as aliases
+var reservedAliases = map[string]bool{
+	"trash":  true,
+	"search": true,
+}
+
 // URLService handles business logic for URL operations
 type URLService struct {
-	repository *repositories.URLRepository
-	cache      *CacheService
+	repository  *repositories.URLRepository
+	revisions   *repositories.RevisionRepository
+	cache       *CacheService
+	generator   utils.ShortCodeGenerator
+	filter      *utils.CodeFilter
+	metadata    *MetadataService
+	webhooks    *WebhookService
+	maxAttempts int
 }
 
 // NewURLService creates a new instance of URLService
-func NewURLService(repository *repositories.URLRepository, cache *CacheService) *URLService {
+func NewURLService(repository *repositories.URLRepository, revisions *repositories.RevisionRepository, cache *CacheService, generator utils.ShortCodeGenerator, filter *utils.CodeFilter, metadata *MetadataService, webhooks *WebhookService, maxAttempts int) *URLService {
 	return &URLService{
-		repository: repository,
-		cache:      cache,
+		repository:  repository,
+		revisions:   revisions,
+		cache:       cache,
+		generator:   generator,
+		filter:      filter,
+		metadata:    metadata,
+		webhooks:    webhooks,
+		maxAttempts: maxAttempts,
 	}
 }
 
-// CreateURL creates a new short URL
-func (s *URLService) CreateURL(originalURL string) (models.URL, error) {
+// CreateURL creates a new short URL on behalf of actor, remembering the API
+// key it was created with, if any
+func (s *URLService) CreateURL(req models.CreateURLRequest, actor models.Actor, apiKey string) (models.URL, error) {
 	// Validate URL
-	if !utils.ValidateURL(originalURL) {
+	if !utils.ValidateURL(req.URL) {
 		return models.URL{}, models.ErrorInvalidURL
 	}
 
 	// Ensure URL has proper protocol prefix
-	originalURL = utils.PrepareURL(originalURL)
+	originalURL := utils.PrepareURL(req.URL)
+
+	// Validate targeting rules
+	rules, err := validateRules(req.Rules)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Validate A/B variants
+	variants, err := validateVariants(req.Variants)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Validate scheduled changes
+	changes, err := validateSchedule(req.ScheduledChanges, nil, time.Now())
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Validate redirect options
+	if err := validateRedirectOptions(req.RedirectOptions); err != nil {
+		return models.URL{}, err
+	}
+
+	// Validate social preview
+	socialPreview, err := validateSocialPreview(req.SocialPreview)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Validate metadata
+	if err := validateMetadataKeys(req.Metadata); err != nil {
+		return models.URL{}, err
+	}
+
+	// Normalize tags and folder
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return models.URL{}, err
+	}
+	folder, err := normalizeFolder(req.Folder)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Create URL object
+	url := models.URL{
+		OriginalURL:      originalURL,
+		Title:            req.Title,
+		Description:      req.Description,
+		Notes:            req.Notes,
+		Metadata:         req.Metadata,
+		Tags:             tags,
+		Folder:           folder,
+		Rules:            rules,
+		Variants:         variants,
+		ActiveFrom:       req.ActiveFrom,
+		ScheduledChanges: changes,
+		RedirectOptions:  req.RedirectOptions,
+		SocialPreview:    socialPreview,
+		CreatorKey:       utils.HashAPIKey(apiKey),
+	}
+
+	// Use the requested alias as is
+	if req.Alias != "" {
+		if !utils.ValidateAlias(req.Alias) || reservedAliases[strings.ToLower(req.Alias)] {
+			return models.URL{}, models.ErrorInvalidAlias
+		}
+		if !s.filter.Allowed(req.Alias) {
+			return models.URL{}, models.ErrorBlockedAlias
+		}
+
+		url.ShortCode = req.Alias
+		createdURL, err := s.repository.CreateURL(url)
+		if err != nil {
+			return models.URL{}, err
+		}
+
+		// Record the initial destination and fetch its page metadata
+		s.destinationChanged(createdURL, "", actor)
+
+		// Store in cache
+		if s.cache != nil {
+			s.cache.SetURL(createdURL)
+		}
+
+		// Notify webhook subscribers
+		s.emit(models.EventLinkCreated, createdURL)
+		return createdURL, nil
+	}
 
 	// Generate a unique short code
-	var shortCode string
-	var err error
-	var url models.URL
-	maxAttempts := 10
+	createdURL, err := s.createWithGeneratedCode(url, s.repository.CreateURL)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Record the initial destination and fetch its page metadata
+	s.destinationChanged(createdURL, "", actor)
+
+	// Store in cache
+	if s.cache != nil {
+		s.cache.SetURL(createdURL)
+	}
 
+	// Notify webhook subscribers
+	s.emit(models.EventLinkCreated, createdURL)
+	return createdURL, nil
+}
+
+// createWithGeneratedCode stores a URL with insert under a newly generated
+// short code, trying another code when one is taken or spells a blocked word
+func (s *URLService) createWithGeneratedCode(url models.URL, insert func(models.URL) (models.URL, error)) (models.URL, error) {
 	// Try multiple times to generate a unique short code
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		shortCode, err = utils.GenerateShortCode()
+	for attempt := 0; attempt < s.maxAttempts; attempt++ {
+		shortCode, err := s.generator.Generate()
 		if err != nil {
 			log.Printf("Error generating short code (attempt %d): %v", attempt+1, err)
 			continue
 		}
 
-		// Create URL object
-		url = models.URL{
-			OriginalURL: originalURL,
-			ShortCode:   shortCode,
+		// Regenerate codes that spell blocked words
+		if !s.filter.Allowed(shortCode) {
+			log.Printf("Rejected generated short code containing a blocked word (attempt %d)", attempt+1)
+			continue
 		}
+		url.ShortCode = shortCode
 
 		// Try to save to database
-		createdURL, err := s.repository.CreateURL(url)
+		createdURL, err := insert(url)
+		if observer, ok := s.generator.(utils.CollisionObserver); ok && (err == nil || err == models.ErrorShortCodeExists) {
+			observer.ObserveCollision(err == models.ErrorShortCodeExists)
+		}
 		if err == nil {
-			// Store in cache
-			if s.cache != nil {
-				s.cache.SetURL(createdURL)
-			}
 			return createdURL, nil
 		}
 		log.Printf("Failed to create URL with short code %s (attempt %d): %v", shortCode, attempt+1, err)
@@ -92,8 +219,10 @@ func (s *URLService) GetURL(shortCode string) (models.URL, error) {
 	return url, nil
 }
 
-// UpdateURL updates an existing URL
-func (s *URLService) UpdateURL(shortCode string, originalURL string) (models.URL, error) {
+// UpdateURL updates the destination of an existing URL on behalf of actor.
+// When expectedVersion is set, the update fails with ErrorVersionMismatch
+// if the URL has changed since that version.
+func (s *URLService) UpdateURL(shortCode string, originalURL string, actor models.Actor, expectedVersion *int) (models.URL, error) {
 	// Validate URL
 	if !utils.ValidateURL(originalURL) {
 		return models.URL{}, models.ErrorInvalidURL
@@ -103,7 +232,132 @@ func (s *URLService) UpdateURL(shortCode string, originalURL string) (models.URL
 	originalURL = utils.PrepareURL(originalURL)
 
 	// Update in database
-	updatedURL, err := s.repository.UpdateURL(shortCode, originalURL)
+	updatedURL, previousURL, err := s.repository.UpdateURL(shortCode, originalURL, expectedVersion)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Record the destination change and refresh its page metadata
+	s.destinationChanged(updatedURL, previousURL, actor)
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// PatchURL applies a JSON Merge Patch to a URL on behalf of actor, changing
+// only the supplied fields. When expectedVersion is set, the patch fails with
+// ErrorVersionMismatch if the URL has changed since that version.
+func (s *URLService) PatchURL(shortCode string, patch models.URLPatch, actor models.Actor, expectedVersion *int) (models.URL, error) {
+	// Validate URL
+	if patch.URL != nil {
+		if !utils.ValidateURL(*patch.URL) {
+			return models.URL{}, models.ErrorInvalidURL
+		}
+		prepared := utils.PrepareURL(*patch.URL)
+		patch.URL = &prepared
+	}
+
+	// Validate metadata
+	for key := range patch.Metadata {
+		if !validMetadataKey(key) {
+			return models.URL{}, models.ErrorInvalidMetadata
+		}
+	}
+
+	// Normalize tags and folder
+	if patch.Tags != nil {
+		tags, err := normalizeTags(*patch.Tags)
+		if err != nil {
+			return models.URL{}, err
+		}
+		patch.Tags = &tags
+	}
+	if patch.Folder != nil {
+		folder, err := normalizeFolder(*patch.Folder)
+		if err != nil {
+			return models.URL{}, err
+		}
+		patch.Folder = &folder
+	}
+
+	// Update in database
+	updatedURL, previousURL, err := s.repository.PatchURL(shortCode, patch, expectedVersion)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Record the destination change and refresh its page metadata
+	s.destinationChanged(updatedURL, previousURL, actor)
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// validateMetadataKeys checks that metadata keys can be stored as document fields
+func validateMetadataKeys(metadata map[string]string) error {
+	for key := range metadata {
+		if !validMetadataKey(key) {
+			return models.ErrorInvalidMetadata
+		}
+	}
+	return nil
+}
+
+// validMetadataKey reports whether a metadata key can be stored as a document field
+func validMetadataKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, ".$")
+}
+
+// UpdateRules replaces the targeting rules of a URL
+func (s *URLService) UpdateRules(shortCode string, rules []models.TargetingRule) (models.URL, error) {
+	// Validate targeting rules
+	rules, err := validateRules(rules)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, err := s.repository.UpdateRules(shortCode, rules)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// UpdateVariants replaces the A/B variants of a URL. Variants keeping
+// their name keep their click counts.
+func (s *URLService) UpdateVariants(shortCode string, variants []models.Variant) (models.URL, error) {
+	// Validate A/B variants
+	variants, err := validateVariants(variants)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database; variants that keep their name keep their clicks
+	updatedURL, err := s.repository.UpdateVariants(shortCode, variants)
 	if err != nil {
 		return models.URL{}, err
 	}
@@ -113,13 +367,223 @@ func (s *URLService) UpdateURL(shortCode string, originalURL string) (models.URL
 		s.cache.SetURL(updatedURL)
 	}
 
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
 	return updatedURL, nil
 }
 
-// DeleteURL deletes a URL
-func (s *URLService) DeleteURL(shortCode string) error {
-	// Delete from database
-	err := s.repository.DeleteURL(shortCode)
+// UpdateSchedule replaces the activation time and scheduled changes of a URL
+func (s *URLService) UpdateSchedule(shortCode string, activeFrom *time.Time, changes []models.ScheduledChange) (models.URL, error) {
+	// Validate scheduled changes against the stored ones
+	existing, err := s.repository.GetURLByShortCode(shortCode)
+	if err != nil {
+		return models.URL{}, err
+	}
+	changes, err = validateSchedule(changes, existing.ScheduledChanges, time.Now())
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	now := time.Now()
+	updatedURL, err := s.repository.UpdateSchedule(shortCode, activeFrom, changes)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers, also when moving the activation time
+	// into the future stops a live link from resolving
+	s.emit(models.EventLinkUpdated, updatedURL)
+	if isActive(existing, now) && !isActive(updatedURL, now) {
+		s.emit(models.EventLinkExpired, updatedURL)
+	}
+
+	return updatedURL, nil
+}
+
+// UpdateRedirectOptions replaces the redirect options of a URL
+func (s *URLService) UpdateRedirectOptions(shortCode string, redirectOptions *models.RedirectOptions) (models.URL, error) {
+	// Validate redirect options
+	if err := validateRedirectOptions(redirectOptions); err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, err := s.repository.UpdateRedirectOptions(shortCode, redirectOptions)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// UpdateSocialPreview replaces the social preview of a URL
+func (s *URLService) UpdateSocialPreview(shortCode string, preview *models.SocialPreview) (models.URL, error) {
+	// Validate social preview
+	preview, err := validateSocialPreview(preview)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, err := s.repository.UpdateSocialPreview(shortCode, preview)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(updatedURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// ApplyScheduledChanges switches every URL with a due scheduled change to
+// its latest due destination and returns how many URLs were changed
+func (s *URLService) ApplyScheduledChanges(now time.Time) (int, error) {
+	urls, err := s.repository.GetURLsWithDueChanges(now)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, url := range urls {
+		change, ok := latestDueChange(url.ScheduledChanges, now)
+		if !ok {
+			continue
+		}
+
+		updatedURL, err := s.repository.ApplyScheduledChange(url.ShortCode, change.URL, now)
+		if err != nil {
+			log.Printf("Error applying scheduled change to %s: %v", url.ShortCode, err)
+			continue
+		}
+		s.destinationChanged(updatedURL, url.OriginalURL, models.Actor{Name: "scheduler"})
+
+		// Invalidate cache so redirects pick up the new destination
+		if s.cache != nil {
+			s.cache.InvalidateURL(url.ShortCode)
+		}
+
+		// Notify webhook subscribers
+		s.emit(models.EventLinkUpdated, updatedURL)
+		applied++
+	}
+
+	return applied, nil
+}
+
+// GetHistory retrieves the destination revisions of a URL, oldest first
+func (s *URLService) GetHistory(shortCode string) ([]models.Revision, error) {
+	url, err := s.repository.GetURLByShortCode(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.revisions.GetRevisions(url.ID)
+}
+
+// Rollback restores the destination a URL had at the given revision.
+// The rollback itself is recorded as a new revision.
+func (s *URLService) Rollback(shortCode string, number int, actor models.Actor) (models.URL, error) {
+	url, err := s.repository.GetURLByShortCode(shortCode)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	revision, err := s.revisions.GetRevision(url.ID, number)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update in database
+	updatedURL, previousURL, err := s.repository.UpdateURL(shortCode, revision.NewURL, nil)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Record the destination change and refresh its page metadata
+	s.destinationChanged(updatedURL, previousURL, actor)
+
+	// Invalidate cache so redirects pick up the restored destination
+	if s.cache != nil {
+		s.cache.InvalidateURL(shortCode)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkUpdated, updatedURL)
+
+	return updatedURL, nil
+}
+
+// isActive reports whether a URL's activation time has been reached at now
+func isActive(url models.URL, now time.Time) bool {
+	return url.ActiveFrom == nil || !now.Before(*url.ActiveFrom)
+}
+
+// emit sends a link event to webhook subscribers
+func (s *URLService) emit(eventType string, url models.URL) {
+	if s.webhooks != nil {
+		s.webhooks.Emit(eventType, url)
+	}
+}
+
+// destinationChanged records a destination change, if there was one, and
+// refreshes the page metadata of the new destination
+func (s *URLService) destinationChanged(url models.URL, previousURL string, actor models.Actor) {
+	if url.OriginalURL == previousURL {
+		return
+	}
+
+	s.recordRevision(url, previousURL, actor)
+	if s.metadata != nil {
+		s.metadata.Enqueue(url)
+	}
+}
+
+// recordRevision stores a destination change.
+// Failures are logged rather than failing the change itself.
+func (s *URLService) recordRevision(url models.URL, previousURL string, actor models.Actor) {
+	if s.revisions == nil {
+		return
+	}
+
+	_, err := s.revisions.AddRevision(models.Revision{
+		URLID:     url.ID,
+		ShortCode: url.ShortCode,
+		OldURL:    previousURL,
+		NewURL:    url.OriginalURL,
+		Actor:     actor.Name,
+		ActorNote: actor.Note,
+	})
+	if err != nil {
+		log.Printf("Error recording revision for %s: %v", url.ShortCode, err)
+	}
+}
+
+// DeleteURL moves a URL to the trash. When expectedVersion is set, it fails
+// with ErrorVersionMismatch if the URL has changed since that version.
+func (s *URLService) DeleteURL(shortCode string, expectedVersion *int) error {
+	// Soft delete in database
+	deletedURL, err := s.repository.DeleteURL(shortCode, expectedVersion)
 	if err != nil {
 		return err
 	}
@@ -129,13 +593,56 @@ func (s *URLService) DeleteURL(shortCode string) error {
 		s.cache.InvalidateURL(shortCode)
 	}
 
+	// Notify webhook subscribers
+	s.emit(models.EventLinkDeleted, deletedURL)
+
 	return nil
 }
 
+// RestoreURL moves a URL out of the trash
+func (s *URLService) RestoreURL(shortCode string) (models.URL, error) {
+	// Restore in database
+	restoredURL, err := s.repository.RestoreURL(shortCode)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	// Update cache
+	if s.cache != nil {
+		s.cache.SetURL(restoredURL)
+	}
+
+	// Notify webhook subscribers
+	s.emit(models.EventLinkRestored, restoredURL)
+
+	return restoredURL, nil
+}
+
+// GetDeletedURLs retrieves all URLs in the trash
+func (s *URLService) GetDeletedURLs() ([]models.URL, error) {
+	return s.repository.GetDeletedURLs()
+}
+
+// PurgeTrash hard-deletes URLs deleted more than retention ago. Their short
+// codes stay reserved until quarantine has passed since deletion.
+func (s *URLService) PurgeTrash(now time.Time, retention, quarantine time.Duration) (int64, error) {
+	purgedURLs, purged, err := s.repository.PurgeDeletedURLs(now.Add(-retention), now.Add(-quarantine))
+
+	// Notify webhook subscribers
+	if s.webhooks != nil {
+		for _, url := range purgedURLs {
+			s.webhooks.EmitPurged(url)
+		}
+	}
+
+	return purged, err
+}
+
 // IncrementAccessCount increments the access count for a URL
-func (s *URLService) IncrementAccessCount(shortCode string) error {
+// and for the variant that was served, if any
+func (s *URLService) IncrementAccessCount(shortCode string, variant string) error {
 	// Increment in database
-	err := s.repository.IncrementAccessCount(shortCode)
+	updatedURL, err := s.repository.IncrementAccessCount(shortCode, variant)
 	if err != nil {
 		return err
 	}
@@ -145,10 +652,38 @@ func (s *URLService) IncrementAccessCount(shortCode string) error {
 		s.cache.InvalidateURL(shortCode)
 	}
 
+	// Notify webhook subscribers of click milestones
+	if s.webhooks != nil {
+		s.webhooks.EmitClicks(updatedURL)
+	}
+
 	return nil
 }
 
-// GetAllURLsWithStats retrieves all URLs with their statistics
-func (s *URLService) GetAllURLsWithStats() ([]models.URL, error) {
-	return s.repository.GetAllURLs()
+// IncrementBotCount increments the bot request count for a URL
+func (s *URLService) IncrementBotCount(shortCode string) error {
+	// Increment in database
+	err := s.repository.IncrementBotCount(shortCode)
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache since the bot count has changed
+	if s.cache != nil {
+		s.cache.InvalidateURL(shortCode)
+	}
+
+	return nil
+}
+
+// GetAllURLsWithStats retrieves all URLs matching the filter with their statistics
+func (s *URLService) GetAllURLsWithStats(filter models.URLFilter) ([]models.URL, error) {
+	if filter.Tag != "" {
+		tag, err := normalizeTag(filter.Tag)
+		if err != nil {
+			return nil, err
+		}
+		filter.Tag = tag
+	}
+	return s.repository.GetAllURLs(filter)
 }
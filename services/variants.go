@@ -0,0 +1,73 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/utils"
+)
+
+// chooseVariant picks the A/B variant for a visitor. A variant remembered
+// from an earlier visit is kept; otherwise the visitor is bucketed by a hash
+// of their IP and User-Agent so repeat visits land on the same variant.
+func chooseVariant(url models.URL, visitor models.Visitor) (models.Variant, bool) {
+	totalWeight := 0
+	for _, variant := range url.Variants {
+		if variant.Name == visitor.AssignedVariant && variant.Weight > 0 {
+			return variant, true
+		}
+		totalWeight += variant.Weight
+	}
+	if totalWeight <= 0 {
+		return models.Variant{}, false
+	}
+
+	bucket := int(visitorHash(url.ShortCode, visitor) % uint64(totalWeight))
+	for _, variant := range url.Variants {
+		if bucket < variant.Weight {
+			return variant, true
+		}
+		bucket -= variant.Weight
+	}
+
+	return models.Variant{}, false
+}
+
+// visitorHash returns a stable per-link fingerprint of a visitor
+func visitorHash(shortCode string, visitor models.Visitor) uint64 {
+	sum := sha256.Sum256([]byte(shortCode + "|" + visitor.IP + "|" + visitor.UserAgent))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// validateVariants checks variants and normalises their destinations
+func validateVariants(variants []models.Variant) ([]models.Variant, error) {
+	seen := make(map[string]bool)
+	validated := make([]models.Variant, 0, len(variants))
+	for i, variant := range variants {
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" {
+			return nil, fmt.Errorf("%w: variant %d has no name", models.ErrorInvalidVariant, i+1)
+		}
+		if seen[variant.Name] {
+			return nil, fmt.Errorf("%w: duplicate variant name %q", models.ErrorInvalidVariant, variant.Name)
+		}
+		seen[variant.Name] = true
+
+		if !utils.ValidateURL(variant.URL) {
+			return nil, fmt.Errorf("%w: variant %q has an invalid URL", models.ErrorInvalidVariant, variant.Name)
+		}
+		variant.URL = utils.PrepareURL(variant.URL)
+
+		if variant.Weight <= 0 {
+			return nil, fmt.Errorf("%w: variant %q must have a positive weight", models.ErrorInvalidVariant, variant.Name)
+		}
+
+		// Click counts are tracked by the server, never set by clients
+		variant.Clicks = 0
+		validated = append(validated, variant)
+	}
+	return validated, nil
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/askarbtw/url-shortener-golang/config"
+	"github.com/askarbtw/url-shortener-golang/models"
+	"github.com/askarbtw/url-shortener-golang/repositories"
+	"github.com/askarbtw/url-shortener-golang/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Subscribers must answer themselves; a redirect counts as a failed attempt
+	maxWebhookRedirects = 0
+	maxWebhookRetryWait = 6 * time.Hour
+	webhookPollInterval = 5 * time.Second
+	// webhookEventQueueSize bounds the events waiting to be turned into deliveries
+	webhookEventQueueSize = 1000
+	// webhookLogLimit bounds the deliveries returned by the delivery log and dead-letter list
+	webhookLogLimit = 100
+)
+
+// WebhookService manages webhook subscriptions and delivers link events to them
+type WebhookService struct {
+	repository  *repositories.WebhookRepository
+	client      *http.Client
+	timeout     time.Duration
+	workers     int
+	maxAttempts int
+	retryBase   time.Duration // Wait before the first retry; doubled for each later one
+	milestones  map[int]bool  // Click counts that emit link.click_milestone
+	events      chan models.WebhookEvent
+	wake        chan struct{}
+}
+
+// NewWebhookService creates a new instance of WebhookService
+func NewWebhookService(repository *repositories.WebhookRepository, conf *config.Config) *WebhookService {
+	milestones := make(map[int]bool)
+	for _, clicks := range conf.WebhookClickMilestones {
+		milestones[clicks] = true
+	}
+
+	return &WebhookService{
+		repository:  repository,
+		client:      newOutboundClient(conf.WebhookTimeout, maxWebhookRedirects, conf.WebhookAllowPrivate),
+		timeout:     conf.WebhookTimeout,
+		workers:     conf.WebhookWorkers,
+		maxAttempts: conf.WebhookMaxAttempts,
+		retryBase:   conf.WebhookRetryBase,
+		milestones:  milestones,
+		events:      make(chan models.WebhookEvent, webhookEventQueueSize),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// CreateSubscription validates and stores a new subscription, generating a
+// secret when none is given. The secret is only ever returned here.
+func (s *WebhookService) CreateSubscription(req models.WebhookSubscriptionRequest) (models.WebhookSubscription, error) {
+	subscription, err := s.subscriptionFromRequest(req)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	if subscription.Secret == "" {
+		if subscription.Secret, err = generateSecret(); err != nil {
+			return models.WebhookSubscription{}, err
+		}
+	}
+
+	return s.repository.CreateSubscription(subscription)
+}
+
+// GetSubscriptions retrieves every subscription, without secrets
+func (s *WebhookService) GetSubscriptions() ([]models.WebhookSubscription, error) {
+	subscriptions, err := s.repository.GetSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription retrieves a subscription, without its secret
+func (s *WebhookService) GetSubscription(id string) (models.WebhookSubscription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.WebhookSubscription{}, models.ErrorWebhookNotFound
+	}
+
+	subscription, err := s.repository.GetSubscription(objectID)
+	subscription.Secret = ""
+	return subscription, err
+}
+
+// UpdateSubscription replaces a subscription's URL, events and active flag,
+// and its secret when a new one is given
+func (s *WebhookService) UpdateSubscription(id string, req models.WebhookSubscriptionRequest) (models.WebhookSubscription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.WebhookSubscription{}, models.ErrorWebhookNotFound
+	}
+
+	subscription, err := s.subscriptionFromRequest(req)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	subscription.ID = objectID
+
+	updated, err := s.repository.UpdateSubscription(subscription)
+	updated.Secret = ""
+	return updated, err
+}
+
+// DeleteSubscription removes a subscription and its pending deliveries
+func (s *WebhookService) DeleteSubscription(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ErrorWebhookNotFound
+	}
+
+	return s.repository.DeleteSubscription(objectID)
+}
+
+// GetDeliveries retrieves the most recent deliveries of a subscription
+func (s *WebhookService) GetDeliveries(id string) ([]models.WebhookDelivery, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrorWebhookNotFound
+	}
+
+	if _, err := s.repository.GetSubscription(objectID); err != nil {
+		return nil, err
+	}
+	return s.repository.GetSubscriptionDeliveries(objectID, webhookLogLimit)
+}
+
+// GetDeadLetters retrieves the most recent deliveries that were given up on
+func (s *WebhookService) GetDeadLetters() ([]models.WebhookDelivery, error) {
+	return s.repository.GetDeadDeliveries(webhookLogLimit)
+}
+
+// RetryDelivery queues a dead delivery again
+func (s *WebhookService) RetryDelivery(id string) (models.WebhookDelivery, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.WebhookDelivery{}, models.ErrorDeliveryNotFound
+	}
+
+	delivery, err := s.repository.RequeueDelivery(objectID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	s.wakeWorkers()
+	return delivery, nil
+}
+
+// subscriptionFromRequest validates a subscription request
+func (s *WebhookService) subscriptionFromRequest(req models.WebhookSubscriptionRequest) (models.WebhookSubscription, error) {
+	if !utils.ValidateURL(req.URL) {
+		return models.WebhookSubscription{}, fmt.Errorf("%w: invalid URL", models.ErrorInvalidWebhook)
+	}
+
+	events := []string{}
+	for _, event := range req.Events {
+		if !containsString(models.EventTypes, event) {
+			return models.WebhookSubscription{}, fmt.Errorf("%w: unknown event %q", models.ErrorInvalidWebhook, event)
+		}
+		if !containsString(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	return models.WebhookSubscription{
+		URL:    utils.PrepareURL(req.URL),
+		Secret: req.Secret,
+		Events: events,
+		Active: active,
+	}, nil
+}
+
+// Emit queues an event about a URL for every active subscription to its type.
+// It never blocks the change that caused the event; when the queue is full
+// the event is dropped.
+func (s *WebhookService) Emit(eventType string, url models.URL) {
+	s.emit(models.WebhookEvent{Type: eventType, Link: newWebhookLink(url)})
+}
+
+// EmitPurged queues link.deleted for a URL purged from the trash for good
+func (s *WebhookService) EmitPurged(url models.URL) {
+	s.emit(models.WebhookEvent{Type: models.EventLinkDeleted, Link: newWebhookLink(url), Purged: true})
+}
+
+// EmitClicks emits link.click_milestone when a URL's access count has just reached a milestone
+func (s *WebhookService) EmitClicks(url models.URL) {
+	if !s.milestones[url.AccessCount] {
+		return
+	}
+	s.emit(models.WebhookEvent{
+		Type:      models.EventLinkClickMilestone,
+		Link:      newWebhookLink(url),
+		Milestone: url.AccessCount,
+	})
+}
+
+// emit stamps an event and hands it to the background queue
+func (s *WebhookService) emit(event models.WebhookEvent) {
+	event.ID = primitive.NewObjectID().Hex()
+	event.CreatedAt = time.Now()
+
+	select {
+	case s.events <- event:
+	default:
+		log.Printf("Webhook event queue full, dropping %s event for %s", event.Type, event.Link.ShortCode)
+	}
+}
+
+// queue stores one delivery of an event per subscriber and wakes the workers
+func (s *WebhookService) queue(event models.WebhookEvent) {
+	subscriptions, err := s.repository.GetActiveSubscriptions(event.Type)
+	if err != nil {
+		log.Printf("Error finding webhook subscriptions for %s: %v", event.Type, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", event.Type, err)
+		return
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  event.CreatedAt,
+			CreatedAt:      event.CreatedAt,
+		})
+	}
+
+	if err := s.repository.CreateDeliveries(deliveries); err != nil {
+		log.Printf("Error queueing %s deliveries: %v", event.Type, err)
+		return
+	}
+	s.wakeWorkers()
+}
+
+// Start runs the event queue and the delivery workers in the background
+// until ctx is cancelled
+func (s *WebhookService) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-s.events:
+				s.queue(event)
+			}
+		}
+	}()
+
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			ticker := time.NewTicker(webhookPollInterval)
+			defer ticker.Stop()
+
+			for {
+				s.deliverDue(ctx)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-s.wake:
+				}
+			}
+		}()
+	}
+}
+
+// wakeWorkers tells an idle worker there are deliveries waiting
+func (s *WebhookService) wakeWorkers() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends deliveries until none are due
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// The lease outlasts a send, so other workers only pick up deliveries whose worker died
+		delivery, ok, err := s.repository.ClaimDueDelivery(time.Now(), s.timeout+time.Minute)
+		if err != nil {
+			log.Printf("Error claiming webhook delivery: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		s.deliver(ctx, delivery)
+	}
+}
+
+// deliver sends one delivery attempt and records its outcome, scheduling a
+// retry with exponential backoff or moving the delivery to the dead letters
+func (s *WebhookService) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	// Deliveries to deleted or deactivated subscriptions are given up on at once
+	giveUp := false
+	subscription, err := s.repository.GetSubscription(delivery.SubscriptionID)
+	switch {
+	case errors.Is(err, models.ErrorWebhookNotFound):
+		giveUp = true
+	case err == nil && !subscription.Active:
+		err = errors.New("subscription is inactive")
+		giveUp = true
+	case err == nil:
+		delivery.LastStatusCode, err = s.send(ctx, subscription, delivery)
+	}
+
+	now := time.Now()
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= s.maxAttempts || giveUp {
+			delivery.Status = models.DeliveryDead
+			log.Printf("Giving up on %s delivery %s: %v", delivery.EventType, delivery.ID.Hex(), err)
+		} else {
+			delivery.NextAttemptAt = now.Add(s.retryWait(delivery.Attempts))
+		}
+	}
+
+	if err := s.repository.UpdateDelivery(delivery); err != nil {
+		log.Printf("Error recording webhook delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// send posts a delivery's payload, signed with the subscription's secret,
+// and returns the response status. Any status other than 2xx is an error.
+func (s *WebhookService) send(ctx context.Context, subscription models.WebhookSubscription, delivery models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "url-shortener-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signPayload(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryWait returns how long to wait after a failed attempt: the retry base
+// doubled for every earlier attempt, capped, with up to 10% jitter so
+// retries to a recovering subscriber are spread out
+func (s *WebhookService) retryWait(attempts int) time.Duration {
+	wait := s.retryBase
+	for i := 1; i < attempts && wait < maxWebhookRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxWebhookRetryWait {
+		wait = maxWebhookRetryWait
+	}
+	return wait + time.Duration(mathrand.Int63n(int64(wait)/10+1))
+}
+
+// signPayload returns the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func signPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateSecret returns a random signing secret
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// newWebhookLink builds the representation of a URL in event payloads
+func newWebhookLink(url models.URL) models.WebhookLink {
+	return models.WebhookLink{
+		ID:          url.ID,
+		URL:         url.OriginalURL,
+		ShortCode:   url.ShortCode,
+		Title:       url.Title,
+		Tags:        url.Tags,
+		Folder:      url.Folder,
+		AccessCount: url.AccessCount,
+		Version:     url.Version,
+		CreatedAt:   url.CreatedAt,
+		UpdatedAt:   url.UpdatedAt,
+		DeletedAt:   url.DeletedAt,
+	}
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
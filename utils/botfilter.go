@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//go:embed bot_patterns.txt
+var defaultBotPatterns string
+
+// BotFilter tells automated requests apart from people
+type BotFilter struct {
+	patterns []string
+}
+
+// NewBotFilter creates a filter for the given User-Agent fragments
+func NewBotFilter(patterns []string) *BotFilter {
+	var normalised []string
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		normalised = append(normalised, pattern)
+	}
+	return &BotFilter{patterns: normalised}
+}
+
+// LoadBotFilter creates a filter from a pattern file, one User-Agent fragment
+// per line, falling back to the built-in list when path is empty
+func LoadBotFilter(path string) (*BotFilter, error) {
+	if path == "" {
+		return NewBotFilter(strings.Split(defaultBotPatterns, "\n")), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewBotFilter(patterns), nil
+}
+
+// IsBot reports whether a request was made by a bot rather than a person
+// following a link: HEAD requests, browser prefetches and previews, requests
+// without a User-Agent and User-Agents matching a known bot pattern
+func (f *BotFilter) IsBot(r *http.Request) bool {
+	if r.Method == http.MethodHead || isPrefetch(r.Header) {
+		return true
+	}
+
+	ua := strings.ToLower(r.UserAgent())
+	if strings.TrimSpace(ua) == "" {
+		return true
+	}
+	if f == nil {
+		return IsLinkPreviewCrawler(ua)
+	}
+	for _, pattern := range f.patterns {
+		if strings.Contains(ua, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefetch reports whether the browser fetched the link speculatively rather
+// than because someone clicked it
+func isPrefetch(header http.Header) bool {
+	for _, name := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(header.Get(name))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "preview") || strings.Contains(value, "prerender") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"os"
+	"strings"
+)
+
+//go:embed blocked_words.txt
+var defaultBlockedWords string
+
+// lookalikes maps characters to the letters they are commonly used to imitate.
+// Characters with more than one reading produce several normalised forms.
+var lookalikes = map[rune][]rune{
+	'0': {'o'},
+	'1': {'i', 'l'},
+	'3': {'e'},
+	'4': {'a'},
+	'5': {'s'},
+	'6': {'g'},
+	'7': {'t'},
+	'8': {'b'},
+	'9': {'g'},
+	'@': {'a'},
+	'$': {'s'},
+	'!': {'i'},
+	'|': {'l'},
+}
+
+// CodeFilter rejects short codes containing blocked words
+type CodeFilter struct {
+	words []string
+}
+
+// NewCodeFilter creates a filter for the given words
+func NewCodeFilter(words []string) *CodeFilter {
+	var normalised []string
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		normalised = append(normalised, word)
+	}
+	return &CodeFilter{words: normalised}
+}
+
+// LoadCodeFilter creates a filter from a word list file, one word per line,
+// falling back to the built-in list when path is empty
+func LoadCodeFilter(path string) (*CodeFilter, error) {
+	if path == "" {
+		return NewCodeFilter(strings.Split(defaultBlockedWords, "\n")), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewCodeFilter(words), nil
+}
+
+// Allowed reports whether a code is free of blocked words
+func (f *CodeFilter) Allowed(code string) bool {
+	if f == nil || len(f.words) == 0 {
+		return true
+	}
+
+	for _, form := range normaliseCode(code) {
+		for _, word := range f.words {
+			if strings.Contains(form, word) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normaliseCode returns every reading of a code with case, separators and
+// lookalike characters folded away
+func normaliseCode(code string) []string {
+	forms := []string{""}
+	for _, r := range strings.ToLower(code) {
+		switch r {
+		case '-', '_', '.', '~':
+			continue
+		}
+
+		readings, ok := lookalikes[r]
+		if !ok {
+			readings = []rune{r}
+		}
+
+		// Cap the number of forms so adversarial aliases stay cheap to check
+		if len(readings) > 1 && len(forms) >= 64 {
+			readings = readings[:1]
+		}
+
+		next := make([]string, 0, len(forms)*len(readings))
+		for _, form := range forms {
+			for _, reading := range readings {
+				next = append(next, form+string(reading))
+			}
+		}
+		forms = next
+	}
+	return forms
+}
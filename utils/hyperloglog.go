@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hyperLogLogPrecision is the number of hash bits picking a register; 2^12
+// registers take 4 KB and count with a standard error of about 1.6%
+const hyperLogLogPrecision = 12
+
+// hyperLogLogSparseLimit is the number of set registers a sketch keeps in a
+// map before switching to the full register array, which is smaller from
+// then on
+const hyperLogLogSparseLimit = 256
+
+// HyperLogLog approximately counts distinct 64-bit hashes in fixed memory.
+// Sketches start sparse, storing only the registers that are set, so the many
+// sketches that only ever see a few hashes stay small.
+type HyperLogLog struct {
+	sparse    map[uint16]uint8
+	registers []uint8 // Nil while the sketch is sparse
+}
+
+// NewHyperLogLog creates an empty HyperLogLog sketch
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{
+		sparse: make(map[uint16]uint8),
+	}
+}
+
+// Add records a hash, which must be uniformly distributed, and reports
+// whether the sketch changed; like Redis PFADD, it never changes for a hash
+// that was added before
+func (h *HyperLogLog) Add(hash uint64) bool {
+	return h.AddRegister(HyperLogLogRegister(hash))
+}
+
+// HyperLogLogRegister returns the register a hash falls in and the rank it
+// sets it to. Sketches stored as registers, such as those kept in click
+// rollups, are merged by keeping the highest rank of each register.
+func HyperLogLogRegister(hash uint64) (int, uint8) {
+	index := int(hash >> (64 - hyperLogLogPrecision))
+	rank := uint8(bits.LeadingZeros64(hash<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1))) + 1
+	return index, rank
+}
+
+// AddRegister raises a register to rank and reports whether the sketch
+// changed. Indexes outside the sketch are ignored.
+func (h *HyperLogLog) AddRegister(index int, rank uint8) bool {
+	if index < 0 || index >= 1<<hyperLogLogPrecision {
+		return false
+	}
+
+	if h.registers != nil {
+		if rank > h.registers[index] {
+			h.registers[index] = rank
+			return true
+		}
+		return false
+	}
+
+	if rank <= h.sparse[uint16(index)] {
+		return false
+	}
+	h.sparse[uint16(index)] = rank
+	if len(h.sparse) > hyperLogLogSparseLimit {
+		h.registers = make([]uint8, 1<<hyperLogLogPrecision)
+		for i, r := range h.sparse {
+			h.registers[i] = r
+		}
+		h.sparse = nil
+	}
+	return true
+}
+
+// Count returns the estimated number of distinct hashes added
+func (h *HyperLogLog) Count() int64 {
+	m := float64(int(1) << hyperLogLogPrecision)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum := 0.0
+	zeros := 0
+	if h.registers != nil {
+		for _, register := range h.registers {
+			sum += math.Ldexp(1, -int(register))
+			if register == 0 {
+				zeros++
+			}
+		}
+	} else {
+		// Registers missing from the map are zero and add 2^0 each
+		zeros = 1<<hyperLogLogPrecision - len(h.sparse)
+		sum = float64(zeros)
+		for _, register := range h.sparse {
+			sum += math.Ldexp(1, -int(register))
+		}
+	}
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
@@ -0,0 +1,413 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ParquetType is the type of a Parquet column
+type ParquetType int
+
+// Column types supported by ParquetWriter
+const (
+	ParquetString    ParquetType = iota // UTF-8 string
+	ParquetInt64                        // 64-bit integer
+	ParquetBool                         // Boolean
+	ParquetTimestamp                    // Milliseconds since the Unix epoch, UTC
+)
+
+// ParquetColumn describes one column of a Parquet file
+type ParquetColumn struct {
+	Name string
+	Type ParquetType
+}
+
+// Parquet format constants, from parquet.thrift
+const (
+	parquetMagic = "PAR1"
+
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecGzip = 2
+
+	parquetDataPage = 0
+)
+
+// ParquetWriter streams rows into a Parquet file. Rows are buffered until a
+// row group is full and then written out, so memory use is bounded by the
+// row group size rather than the file size. It writes the simplest layout
+// every reader understands: required columns, plain encoding and one
+// gzip-compressed data page per column chunk.
+type ParquetWriter struct {
+	w            *countingWriter
+	columns      []ParquetColumn
+	rowGroupSize int
+	values       []*bytes.Buffer // Plain-encoded values of each column in the current row group
+	bools        [][]bool        // Boolean columns are bit-packed when the row group is flushed
+	rows         int
+	totalRows    int64
+	rowGroups    []parquetRowGroup
+}
+
+// parquetRowGroup records where a row group was written, for the footer
+type parquetRowGroup struct {
+	rows    int64
+	columns []parquetColumnChunk
+}
+
+// parquetColumnChunk records where a column chunk was written, for the footer
+type parquetColumnChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+	values           int64
+}
+
+// countingWriter tracks the offset reached in the output
+type countingWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+// NewParquetWriter starts a Parquet file with the given columns on w
+func NewParquetWriter(w io.Writer, columns []ParquetColumn, rowGroupSize int) (*ParquetWriter, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	if rowGroupSize <= 0 {
+		rowGroupSize = 10000
+	}
+
+	writer := &ParquetWriter{
+		w:            &countingWriter{w: w},
+		columns:      columns,
+		rowGroupSize: rowGroupSize,
+		values:       make([]*bytes.Buffer, len(columns)),
+		bools:        make([][]bool, len(columns)),
+	}
+	for i := range columns {
+		writer.values[i] = &bytes.Buffer{}
+	}
+
+	if _, err := io.WriteString(writer.w, parquetMagic); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// Write adds a row. Values must match the column types: string, int64, bool
+// and time.Time.
+func (p *ParquetWriter) Write(row []interface{}) error {
+	if len(row) != len(p.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(p.columns))
+	}
+
+	for i, column := range p.columns {
+		var ok bool
+		switch column.Type {
+		case ParquetString:
+			var value string
+			if value, ok = row[i].(string); ok {
+				binary.Write(p.values[i], binary.LittleEndian, uint32(len(value)))
+				p.values[i].WriteString(value)
+			}
+		case ParquetInt64:
+			var value int64
+			if value, ok = row[i].(int64); ok {
+				binary.Write(p.values[i], binary.LittleEndian, value)
+			}
+		case ParquetBool:
+			var value bool
+			if value, ok = row[i].(bool); ok {
+				p.bools[i] = append(p.bools[i], value)
+			}
+		case ParquetTimestamp:
+			var value time.Time
+			if value, ok = row[i].(time.Time); ok {
+				binary.Write(p.values[i], binary.LittleEndian, value.UnixMilli())
+			}
+		}
+		if !ok {
+			return fmt.Errorf("parquet: column %s got %T", column.Name, row[i])
+		}
+	}
+
+	p.rows++
+	if p.rows >= p.rowGroupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer; it does not close the
+// underlying writer
+func (p *ParquetWriter) Close() error {
+	if p.rows > 0 {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+
+	footer := p.fileMetaData()
+	if _, err := p.w.Write(footer); err != nil {
+		return err
+	}
+	if err := binary.Write(p.w, binary.LittleEndian, uint32(len(footer))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(p.w, parquetMagic)
+	return err
+}
+
+// flush writes the buffered rows as a row group
+func (p *ParquetWriter) flush() error {
+	group := parquetRowGroup{rows: int64(p.rows)}
+
+	for i, column := range p.columns {
+		if column.Type == ParquetBool {
+			packed := make([]byte, (len(p.bools[i])+7)/8)
+			for j, value := range p.bools[i] {
+				if value {
+					packed[j/8] |= 1 << (j % 8)
+				}
+			}
+			p.values[i].Write(packed)
+			p.bools[i] = p.bools[i][:0]
+		}
+
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(p.values[i].Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		header := p.pageHeader(p.values[i].Len(), compressed.Len())
+		chunk := parquetColumnChunk{
+			offset:           p.w.offset,
+			uncompressedSize: int64(len(header) + p.values[i].Len()),
+			compressedSize:   int64(len(header) + compressed.Len()),
+			values:           int64(p.rows),
+		}
+		if _, err := p.w.Write(header); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+
+		group.columns = append(group.columns, chunk)
+		p.values[i].Reset()
+	}
+
+	p.rowGroups = append(p.rowGroups, group)
+	p.totalRows += int64(p.rows)
+	p.rows = 0
+	return nil
+}
+
+// pageHeader encodes the PageHeader of a data page
+func (p *ParquetWriter) pageHeader(uncompressedSize int, compressedSize int) []byte {
+	t := &thriftWriter{}
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(uncompressedSize))
+	t.i32(3, int32(compressedSize))
+	t.beginStruct(5) // DataPageHeader
+	t.i32(1, int32(p.rows))
+	t.i32(2, parquetEncodingPlain)
+	t.i32(3, parquetEncodingRLE)
+	t.i32(4, parquetEncodingRLE)
+	t.endStruct()
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// fileMetaData encodes the FileMetaData footer
+func (p *ParquetWriter) fileMetaData() []byte {
+	t := &thriftWriter{}
+	t.i32(1, 1) // Format version
+
+	// Schema: a root element followed by one leaf per column
+	t.listBegin(2, thriftStruct, len(p.columns)+1)
+	t.beginElement()
+	t.string(4, "schema")
+	t.i32(5, int32(len(p.columns)))
+	t.endStruct()
+	for _, column := range p.columns {
+		t.beginElement()
+		t.i32(1, column.physicalType())
+		t.i32(3, parquetRequired)
+		t.string(4, column.Name)
+		if converted, ok := column.convertedType(); ok {
+			t.i32(6, converted)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, p.totalRows)
+
+	t.listBegin(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		t.beginElement()
+		var totalSize int64
+		t.listBegin(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			totalSize += chunk.uncompressedSize
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3) // ColumnMetaData
+			t.i32(1, p.columns[i].physicalType())
+			t.listBegin(2, thriftI32, 1)
+			t.varint(zigzag(parquetEncodingPlain))
+			t.listBegin(3, thriftBinary, 1)
+			t.varint(uint64(len(p.columns[i].Name)))
+			t.buf.WriteString(p.columns[i].Name)
+			t.i32(4, parquetCodecGzip)
+			t.i64(5, chunk.values)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, totalSize)
+		t.i64(3, group.rows)
+		t.endStruct()
+	}
+
+	t.string(6, "url-shortener")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// physicalType returns the Parquet type a column is stored as
+func (c ParquetColumn) physicalType() int32 {
+	switch c.Type {
+	case ParquetInt64, ParquetTimestamp:
+		return parquetInt64
+	case ParquetBool:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// convertedType returns how readers should interpret a column's physical type
+func (c ParquetColumn) convertedType() (int32, bool) {
+	switch c.Type {
+	case ParquetString:
+		return parquetConvertedUTF8, true
+	case ParquetTimestamp:
+		return parquetConvertedTimestampMillis, true
+	default:
+		return 0, false
+	}
+}
+
+// Thrift compact protocol type codes
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which
+// Parquet uses for its page headers and footer
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], value)
+	t.buf.Write(scratch[:n])
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(value))
+}
+
+func (t *thriftWriter) string(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+// beginStruct starts a struct-valued field
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement starts a struct inside a list
+func (t *thriftWriter) beginElement() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1 ^ value>>63)
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field id
+// to value, so the tests can check the footer without a Parquet library
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	r.t.Helper()
+	if r.pos >= len(r.data) {
+		r.t.Fatalf("thrift: read past the end at %d", r.pos)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	r.t.Helper()
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("thrift: bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	r.t.Helper()
+	fields := make(map[int16]interface{})
+	var lastID int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(unzigzag(r.varint()))
+		}
+		fields[id] = r.readValue(header & 0x0f)
+		lastID = id
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) interface{} {
+	r.t.Helper()
+	switch typ {
+	case thriftI32, thriftI64:
+		return unzigzag(r.varint())
+	case thriftBinary:
+		n := int(r.varint())
+		value := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return value
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.readValue(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	default:
+		r.t.Fatalf("thrift: unexpected type %d at %d", typ, r.pos)
+		return nil
+	}
+}
+
+func unzigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+var testParquetColumns = []ParquetColumn{
+	{Name: "name", Type: ParquetString},
+	{Name: "count", Type: ParquetInt64},
+	{Name: "bot", Type: ParquetBool},
+	{Name: "at", Type: ParquetTimestamp},
+}
+
+func testParquetRow(i int) []interface{} {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute)
+	return []interface{}{fmt.Sprintf("row-%d", i), int64(i * i), i%2 == 0, at}
+}
+
+func TestParquetWriterFileLayout(t *testing.T) {
+	const rows = 7
+	const rowGroupSize = 3
+
+	var buf bytes.Buffer
+	writer, err := NewParquetWriter(&buf, testParquetColumns, rowGroupSize)
+	if err != nil {
+		t.Fatalf("NewParquetWriter: %v", err)
+	}
+	for i := 0; i < rows; i++ {
+		if err := writer.Write(testParquetRow(i)); err != nil {
+			t.Fatalf("Write row %d: %v", i, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	data := buf.Bytes()
+
+	// A Parquet file starts and ends with the magic, with the footer length before the last one
+	if len(data) < 12 || string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatalf("file does not start and end with %q", parquetMagic)
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLength
+	if footerStart < 4 {
+		t.Fatalf("footer length %d does not fit in a %d byte file", footerLength, len(data))
+	}
+
+	reader := &thriftReader{t: t, data: data[:len(data)-8], pos: footerStart}
+	footer := reader.readStruct()
+	if reader.pos != len(data)-8 {
+		t.Errorf("footer decoded to offset %d, want it to end at %d", reader.pos, len(data)-8)
+	}
+
+	if footer[1] != int64(1) {
+		t.Errorf("version = %v, want 1", footer[1])
+	}
+	if footer[3] != int64(rows) {
+		t.Errorf("num_rows = %v, want %d", footer[3], rows)
+	}
+
+	schema := footer[2].([]interface{})
+	if len(schema) != len(testParquetColumns)+1 {
+		t.Fatalf("schema has %d elements, want a root and %d columns", len(schema), len(testParquetColumns))
+	}
+	if root := schema[0].(map[int16]interface{}); root[5] != int64(len(testParquetColumns)) {
+		t.Errorf("root num_children = %v, want %d", root[5], len(testParquetColumns))
+	}
+	for i, column := range testParquetColumns {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != column.Name || element[1] != int64(column.physicalType()) {
+			t.Errorf("schema column %d = %v %v, want %s %d", i, element[4], element[1], column.Name, column.physicalType())
+		}
+	}
+
+	groups := footer[4].([]interface{})
+	if len(groups) != 3 {
+		t.Fatalf("%d row groups, want 3", len(groups))
+	}
+
+	next := 0
+	for g, value := range groups {
+		group := value.(map[int16]interface{})
+		groupRows := int(group[3].(int64))
+		want := rowGroupSize
+		if rows-next < want {
+			want = rows - next
+		}
+		if groupRows != want {
+			t.Errorf("row group %d has %d rows, want %d", g, groupRows, want)
+		}
+
+		chunks := group[1].([]interface{})
+		if len(chunks) != len(testParquetColumns) {
+			t.Fatalf("row group %d has %d column chunks, want %d", g, len(chunks), len(testParquetColumns))
+		}
+		for c, value := range chunks {
+			meta := value.(map[int16]interface{})[3].(map[int16]interface{})
+			if meta[5] != int64(groupRows) {
+				t.Errorf("row group %d column %d has %v values, want %d", g, c, meta[5], groupRows)
+			}
+			values := readParquetPage(t, data, meta[9].(int64), meta[7].(int64), testParquetColumns[c].Type, groupRows)
+			for r, got := range values {
+				if want := testParquetRow(next + r)[c]; got != want {
+					t.Errorf("row %d column %s = %v, want %v", next+r, testParquetColumns[c].Name, got, want)
+				}
+			}
+		}
+		next += groupRows
+	}
+	if next != rows {
+		t.Errorf("row groups hold %d rows, want %d", next, rows)
+	}
+}
+
+// readParquetPage decodes the single data page of a column chunk
+func readParquetPage(t *testing.T, data []byte, offset int64, chunkSize int64, typ ParquetType, rows int) []interface{} {
+	t.Helper()
+
+	reader := &thriftReader{t: t, data: data, pos: int(offset)}
+	header := reader.readStruct()
+	compressedSize := int(header[3].(int64))
+	if int64(reader.pos)-offset+int64(compressedSize) != chunkSize {
+		t.Fatalf("page at %d: header and data take %d bytes, want the chunk size %d", offset, int64(reader.pos)-offset+int64(compressedSize), chunkSize)
+	}
+	if page := header[5].(map[int16]interface{}); page[1] != int64(rows) {
+		t.Errorf("page at %d has %v values, want %d", offset, page[1], rows)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[reader.pos : reader.pos+compressedSize]))
+	if err != nil {
+		t.Fatalf("page at %d: %v", offset, err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("page at %d: %v", offset, err)
+	}
+	if len(plain) != int(header[2].(int64)) {
+		t.Errorf("page at %d has %d bytes uncompressed, header says %v", offset, len(plain), header[2])
+	}
+
+	values := make([]interface{}, rows)
+	for i := range values {
+		switch typ {
+		case ParquetString:
+			n := int(binary.LittleEndian.Uint32(plain))
+			values[i] = string(plain[4 : 4+n])
+			plain = plain[4+n:]
+		case ParquetInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(plain))
+			plain = plain[8:]
+		case ParquetTimestamp:
+			values[i] = time.UnixMilli(int64(binary.LittleEndian.Uint64(plain))).UTC()
+			plain = plain[8:]
+		case ParquetBool:
+			values[i] = plain[i/8]&(1<<(i%8)) != 0
+		}
+	}
+	return values
+}
+
+func TestParquetWriterRejectsMismatchedRows(t *testing.T) {
+	writer, err := NewParquetWriter(io.Discard, testParquetColumns, 10)
+	if err != nil {
+		t.Fatalf("NewParquetWriter: %v", err)
+	}
+
+	if err := writer.Write([]interface{}{"short"}); err == nil {
+		t.Errorf("Write of a row with too few values succeeded")
+	}
+	if err := writer.Write([]interface{}{"name", 1, true, time.Now()}); err == nil {
+		t.Errorf("Write of an int into an int64 column succeeded")
+	}
+}
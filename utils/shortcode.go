@@ -2,34 +2,260 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"log"
 	"math/big"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/askarbtw/url-shortener-golang/models"
 )
 
 const (
-	// Characters used for generating short codes
-	charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	// Length of the generated short code
-	shortCodeLength = 6
+	// DefaultAlphabet is the full base62 alphabet used for short codes
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// UnambiguousAlphabet leaves out characters that are easily confused in print (0/O, 1/l/I)
+	UnambiguousAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// Default length of randomly generated short codes
+	DefaultShortCodeLength = 6
 )
 
-// GenerateShortCode generates a random short code
-func GenerateShortCode() (string, error) {
-	shortCode := make([]byte, shortCodeLength)
-	charsetLength := big.NewInt(int64(len(charset)))
+// ShortCodeGenerator produces candidate short codes
+type ShortCodeGenerator interface {
+	Generate() (string, error)
+}
+
+// CollisionObserver is implemented by generators that adapt to how often
+// their codes collide with existing ones
+type CollisionObserver interface {
+	ObserveCollision(collided bool)
+}
+
+// validateAlphabet checks that an alphabet can encode short codes
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return models.ErrorInvalidAlphabet
+	}
+	seen := make(map[byte]bool)
+	for i := 0; i < len(alphabet); i++ {
+		// Anything else could split the redirect path or read as the preview suffix
+		if seen[alphabet[i]] || !isCodeChar(alphabet[i]) {
+			return models.ErrorInvalidAlphabet
+		}
+		seen[alphabet[i]] = true
+	}
+	return nil
+}
+
+// isCodeChar reports whether a character may appear in a generated short code
+func isCodeChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// RandomGenerator draws codes uniformly from an alphabet. When the share of
+// collisions over the last window of attempts reaches the growth threshold,
+// the code length grows by one.
+type RandomGenerator struct {
+	alphabet        string
+	growthThreshold float64
+	growthWindow    int
+
+	mu         sync.Mutex
+	length     int
+	attempts   int
+	collisions int
+}
+
+// NewRandomGenerator creates a new instance of RandomGenerator.
+// A growthThreshold of 0 disables automatic length growth.
+func NewRandomGenerator(alphabet string, length int, growthThreshold float64, growthWindow int) (*RandomGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		length = DefaultShortCodeLength
+	}
+	return &RandomGenerator{
+		alphabet:        alphabet,
+		length:          length,
+		growthThreshold: growthThreshold,
+		growthWindow:    growthWindow,
+	}, nil
+}
+
+// Generate generates a random short code
+func (g *RandomGenerator) Generate() (string, error) {
+	g.mu.Lock()
+	length := g.length
+	g.mu.Unlock()
+
+	shortCode := make([]byte, length)
+	alphabetLength := big.NewInt(int64(len(g.alphabet)))
 
-	for i := 0; i < shortCodeLength; i++ {
-		randomIndex, err := rand.Int(rand.Reader, charsetLength)
+	for i := 0; i < length; i++ {
+		randomIndex, err := rand.Int(rand.Reader, alphabetLength)
 		if err != nil {
 			return "", err
 		}
-		shortCode[i] = charset[randomIndex.Int64()]
+		shortCode[i] = g.alphabet[randomIndex.Int64()]
 	}
 
 	return string(shortCode), nil
 }
 
+// ObserveCollision records the outcome of an attempt to store a generated code
+func (g *RandomGenerator) ObserveCollision(collided bool) {
+	if g.growthThreshold <= 0 || g.growthWindow <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.attempts++
+	if collided {
+		g.collisions++
+	}
+	if g.attempts < g.growthWindow {
+		return
+	}
+
+	rate := float64(g.collisions) / float64(g.attempts)
+	if rate >= g.growthThreshold {
+		g.length++
+		log.Printf("Short code collision rate %.2f reached threshold, growing length to %d", rate, g.length)
+	}
+	g.attempts = 0
+	g.collisions = 0
+}
+
+// CounterGenerator encodes a monotonically increasing sequence number in an alphabet
+type CounterGenerator struct {
+	alphabet string
+	next     func() (uint64, error)
+}
+
+// NewCounterGenerator creates a new instance of CounterGenerator.
+// next must return a new sequence number on every call.
+func NewCounterGenerator(alphabet string, next func() (uint64, error)) (*CounterGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	return &CounterGenerator{
+		alphabet: alphabet,
+		next:     next,
+	}, nil
+}
+
+// Generate generates the short code for the next sequence number
+func (g *CounterGenerator) Generate() (string, error) {
+	id, err := g.next()
+	if err != nil {
+		return "", err
+	}
+	return EncodeID(id, g.alphabet), nil
+}
+
+// EncodeID encodes a number in the given alphabet, most significant digit first
+func EncodeID(id uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if id == 0 {
+		return alphabet[:1]
+	}
+
+	var encoded []byte
+	for id > 0 {
+		encoded = append(encoded, alphabet[id%base])
+		id /= base
+	}
+
+	// Reverse into most-significant-first order
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// obfuscationBits is the size of the space sequence numbers are permuted in;
+// 2^40 IDs fit in 7 base62 characters
+const obfuscationBits = 40
+
+// ObfuscatedGenerator turns sequence numbers into codes that do not reveal
+// their order, in the spirit of Hashids/Sqids: each ID is run through a
+// salted bijective permutation and encoded in a salt-shuffled alphabet.
+// Codes are padded to minLength.
+type ObfuscatedGenerator struct {
+	alphabet   string
+	multiplier uint64
+	mask       uint64
+	minLength  int
+	next       func() (uint64, error)
+}
+
+// NewObfuscatedGenerator creates a new instance of ObfuscatedGenerator.
+// next must return a new sequence number on every call.
+func NewObfuscatedGenerator(alphabet string, salt string, minLength int, next func() (uint64, error)) (*ObfuscatedGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	seed := sha256.Sum256([]byte(salt))
+	space := uint64(1)<<obfuscationBits - 1
+
+	return &ObfuscatedGenerator{
+		alphabet: shuffleAlphabet(alphabet, seed[:]),
+		// An odd multiplier is invertible modulo a power of two, keeping the permutation bijective
+		multiplier: (binary.BigEndian.Uint64(seed[0:8]) & space) | 1,
+		mask:       binary.BigEndian.Uint64(seed[8:16]) & space,
+		minLength:  minLength,
+		next:       next,
+	}, nil
+}
+
+// Generate generates the obfuscated short code for the next sequence number
+func (g *ObfuscatedGenerator) Generate() (string, error) {
+	id, err := g.next()
+	if err != nil {
+		return "", err
+	}
+	if id >= uint64(1)<<obfuscationBits {
+		return "", errors.New("sequence number exceeds obfuscation space")
+	}
+
+	space := uint64(1)<<obfuscationBits - 1
+	permuted := ((id * g.multiplier) & space) ^ g.mask
+
+	code := EncodeID(permuted, g.alphabet)
+	if len(code) < g.minLength {
+		code = strings.Repeat(g.alphabet[:1], g.minLength-len(code)) + code
+	}
+	return code, nil
+}
+
+// shuffleAlphabet deterministically shuffles an alphabet using a seed
+func shuffleAlphabet(alphabet string, seed []byte) string {
+	shuffled := []byte(alphabet)
+	state := sha256.Sum256(seed)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		state = sha256.Sum256(state[:])
+		j := int(binary.BigEndian.Uint64(state[:8]) % uint64(i+1))
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return string(shuffled)
+}
+
+// aliasPattern matches custom short codes chosen by users
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// ValidateAlias checks that a custom short code is 3-32 letters, digits, '-' or '_'
+func ValidateAlias(alias string) bool {
+	return aliasPattern.MatchString(alias)
+}
+
 // ValidateURL performs validation on a URL
 func ValidateURL(urlStr string) bool {
 	// Basic length check
@@ -0,0 +1,81 @@
+package utils
+
+import "strings"
+
+// Platforms recognised by DetectPlatform
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+// KnownPlatforms lists every value DetectPlatform can return
+var KnownPlatforms = []string{
+	PlatformIOS,
+	PlatformAndroid,
+	PlatformWindows,
+	PlatformMacOS,
+	PlatformLinux,
+	PlatformOther,
+}
+
+// DetectPlatform derives the operating system from a User-Agent header
+func DetectPlatform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	// Order matters: Android UAs contain "linux" and iOS UAs contain "mac os x"
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "windows"):
+		return PlatformWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return PlatformMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return PlatformLinux
+	default:
+		return PlatformOther
+	}
+}
+
+// linkPreviewCrawlers are User-Agent fragments of the bots chat apps and
+// social networks use to unfurl pasted links
+var linkPreviewCrawlers = []string{
+	"facebookexternalhit",
+	"facebookcatalog",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"slack-imgproxy",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"microsoftpreview",
+	"redditbot",
+	"pinterestbot",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"mastodon",
+	"bluesky cardyb",
+	"mattermost-bot",
+	"google-pagerenderer",
+}
+
+// IsLinkPreviewCrawler reports whether a User-Agent belongs to a link-unfurling crawler
+func IsLinkPreviewCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, crawler := range linkPreviewCrawlers {
+		if strings.Contains(ua, crawler) {
+			return true
+		}
+	}
+	return false
+}